@@ -3,9 +3,23 @@ package ghttp
 import (
 	"math"
 	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	// Vendor headers that report when a rate limit window resets, in either delta-seconds
+	// or Unix epoch seconds.
+	rateLimitResetHeaders = []string{
+		"RateLimit-Reset",
+		"X-RateLimit-Reset",
+		"X-Rate-Limit-Reset",
+	}
+)
+
 type (
 	// Backoff is the interface defines a backoff for a retrier. It is called
 	// after a failing request to determine the amount of time
@@ -15,33 +29,132 @@ type (
 		Wait(attemptNum int, resp *Response, err error) time.Duration
 	}
 
+	// BackoffFunc is an adapter to allow the use of ordinary functions as Backoff.
+	BackoffFunc func(attemptNum int, resp *Response, err error) time.Duration
+
+	// BackoffOption configures a backoff.
+	BackoffOption func(bo *backoffOptions)
+
+	backoffOptions struct {
+		rand *jitterRand
+	}
+
+	// A random source for jitter, safe for concurrent use.
+	// The top-level functions of math/rand are used if rnd is nil.
+	jitterRand struct {
+		mu  sync.Mutex
+		rnd *rand.Rand
+	}
+
 	constantBackoff struct {
 		interval time.Duration
 		jitter   bool
+		rand     *jitterRand
 	}
 
 	exponentialBackoff struct {
 		baseInterval time.Duration
 		maxInterval  time.Duration
 		jitter       bool
+		rand         *jitterRand
+	}
+
+	equalJitterBackoff struct {
+		baseInterval time.Duration
+		maxInterval  time.Duration
+		rand         *jitterRand
+	}
+
+	decorrelatedJitterBackoff struct {
+		baseInterval time.Duration
+		maxInterval  time.Duration
+		rand         *jitterRand
+	}
+
+	linearBackoff struct {
+		interval    time.Duration
+		maxInterval time.Duration
+		jitter      bool
+		rand        *jitterRand
 	}
 
 	fibonacciBackoff struct {
 		maxValue int
 		interval time.Duration
 	}
+
+	retryAfterBackoff struct {
+		fallback Backoff
+		max      time.Duration
+	}
+
+	maxDelayBackoff struct {
+		backoff Backoff
+		max     time.Duration
+	}
+
+	minDelayBackoff struct {
+		backoff Backoff
+		min     time.Duration
+	}
+
+	sequenceBackoff struct {
+		delays []time.Duration
+	}
+)
+
+var (
+	globalJitterRand = &jitterRand{}
 )
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+// Wait implements Backoff interface.
+func (f BackoffFunc) Wait(attemptNum int, resp *Response, err error) time.Duration {
+	return f(attemptNum, resp, err)
+}
+
+// WithBackoffSeed is a backoff option that specifies the seed of the random source for jitter,
+// so the delays are deterministic.
+// By default the delays are randomized by the top-level functions of math/rand.
+func WithBackoffSeed(seed int64) BackoffOption {
+	return func(bo *backoffOptions) {
+		bo.rand = &jitterRand{rnd: rand.New(rand.NewSource(seed))}
+	}
+}
+
+func newBackoffOptions(opts []BackoffOption) *backoffOptions {
+	bo := &backoffOptions{rand: globalJitterRand}
+	for _, opt := range opts {
+		opt(bo)
+	}
+	return bo
+}
+
+// Return a random duration in [0, n), or 0 if n less than or equal to zero.
+func (jr *jitterRand) duration(n time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+
+	if jr.rnd == nil {
+		return time.Duration(rand.Int63n(int64(n)))
+	}
+
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	return time.Duration(jr.rnd.Int63n(int64(n)))
+}
+
 // NewConstantBackoff provides a callback for the retry policy which
 // will perform constant backoff with jitter based on interval.
-func NewConstantBackoff(interval time.Duration, jitter bool) Backoff {
+func NewConstantBackoff(interval time.Duration, jitter bool, opts ...BackoffOption) Backoff {
 	return &constantBackoff{
 		interval: interval,
 		jitter:   jitter,
+		rand:     newBackoffOptions(opts).rand,
 	}
 }
 
@@ -51,30 +164,101 @@ func (cb *constantBackoff) Wait(int, *Response, error) time.Duration {
 		return cb.interval
 	}
 
-	return cb.interval/2 + time.Duration(rand.Int63n(int64(cb.interval)))
+	return cb.interval/2 + cb.rand.duration(cb.interval)
 }
 
 // NewExponentialBackoff provides a callback for the retry policy which
 // will perform exponential backoff with jitter based on the attempt number and limited
 // by baseInterval and maxInterval.
 // See: https://aws.amazon.com/cn/blogs/architecture/exponential-backoff-and-jitter/
-func NewExponentialBackoff(baseInterval, maxInterval time.Duration, jitter bool) Backoff {
+func NewExponentialBackoff(baseInterval, maxInterval time.Duration, jitter bool, opts ...BackoffOption) Backoff {
 	return &exponentialBackoff{
 		baseInterval: baseInterval,
 		maxInterval:  maxInterval,
 		jitter:       jitter,
+		rand:         newBackoffOptions(opts).rand,
 	}
 }
 
 // Wait implements Backoff interface.
 func (eb *exponentialBackoff) Wait(attemptNum int, _ *Response, _ error) time.Duration {
-	temp := math.Min(float64(eb.maxInterval), float64(eb.baseInterval)*math.Exp2(float64(attemptNum)))
+	temp := exponential(eb.baseInterval, eb.maxInterval, attemptNum)
 	if !eb.jitter {
-		return time.Duration(temp)
+		return temp
+	}
+
+	n := temp / 2
+	return n + eb.rand.duration(n)
+}
+
+// NewEqualJitterBackoff provides a callback for the retry policy which
+// will perform exponential backoff with "Equal Jitter", that is, keeps half of
+// the exponential delay and randomizes the other half.
+// See: https://aws.amazon.com/cn/blogs/architecture/exponential-backoff-and-jitter/
+func NewEqualJitterBackoff(baseInterval, maxInterval time.Duration, opts ...BackoffOption) Backoff {
+	return &equalJitterBackoff{
+		baseInterval: baseInterval,
+		maxInterval:  maxInterval,
+		rand:         newBackoffOptions(opts).rand,
+	}
+}
+
+// Wait implements Backoff interface.
+func (eb *equalJitterBackoff) Wait(attemptNum int, _ *Response, _ error) time.Duration {
+	temp := exponential(eb.baseInterval, eb.maxInterval, attemptNum)
+	return temp/2 + eb.rand.duration(temp/2+1)
+}
+
+// NewDecorrelatedJitterBackoff provides a callback for the retry policy which
+// will perform backoff with "Decorrelated Jitter", that is, a random delay between
+// baseInterval and three times the previous delay, limited by maxInterval.
+// The previous delays are replayed from attemptNum rather than kept by the backoff,
+// so it's safe to share it between the requests which are sent concurrently.
+// See: https://aws.amazon.com/cn/blogs/architecture/exponential-backoff-and-jitter/
+func NewDecorrelatedJitterBackoff(baseInterval, maxInterval time.Duration, opts ...BackoffOption) Backoff {
+	return &decorrelatedJitterBackoff{
+		baseInterval: baseInterval,
+		maxInterval:  maxInterval,
+		rand:         newBackoffOptions(opts).rand,
+	}
+}
+
+// Wait implements Backoff interface.
+func (db *decorrelatedJitterBackoff) Wait(attemptNum int, _ *Response, _ error) time.Duration {
+	delay := db.baseInterval
+	for i := 0; i <= attemptNum; i++ {
+		delay = db.baseInterval + db.rand.duration(delay*3-db.baseInterval+1)
+		if delay > db.maxInterval {
+			delay = db.maxInterval
+		}
+	}
+	return delay
+}
+
+// NewLinearBackoff provides a callback for the retry policy which
+// will perform linear backoff with jitter based on the attempt number, that is,
+// interval, 2*interval, 3*interval and so on, limited by maxInterval.
+// If maxInterval less than or equal to zero, it means no limit.
+func NewLinearBackoff(interval, maxInterval time.Duration, jitter bool, opts ...BackoffOption) Backoff {
+	return &linearBackoff{
+		interval:    interval,
+		maxInterval: maxInterval,
+		jitter:      jitter,
+		rand:        newBackoffOptions(opts).rand,
+	}
+}
+
+// Wait implements Backoff interface.
+func (lb *linearBackoff) Wait(attemptNum int, _ *Response, _ error) time.Duration {
+	delay := lb.interval * time.Duration(attemptNum+1)
+	if lb.maxInterval > 0 && delay > lb.maxInterval {
+		delay = lb.maxInterval
+	}
+	if !lb.jitter {
+		return delay
 	}
 
-	n := int64(temp / 2)
-	return time.Duration(n + rand.Int63n(n))
+	return delay/2 + lb.rand.duration(delay)
 }
 
 // NewFibonacciBackoff provides a callback for the retry policy which
@@ -99,3 +283,134 @@ func (fb *fibonacciBackoff) Wait(attemptNum int, _ *Response, _ error) time.Dura
 	}
 	return time.Duration(a) * fb.interval
 }
+
+// NewRetryAfterBackoff provides a callback for the retry policy which
+// honors the delay the server asks for through the Retry-After header (either
+// delta-seconds or HTTP-date) or common rate limit reset headers such as X-RateLimit-Reset.
+// The delay is limited by max while max less than or equal to zero means no limit.
+// If the response doesn't tell a delay, it falls back to fallback.
+func NewRetryAfterBackoff(fallback Backoff, max time.Duration) Backoff {
+	return &retryAfterBackoff{
+		fallback: fallback,
+		max:      max,
+	}
+}
+
+// Wait implements Backoff interface.
+func (rb *retryAfterBackoff) Wait(attemptNum int, resp *Response, err error) time.Duration {
+	delay, ok := retryAfter(resp, time.Now())
+	if !ok {
+		return rb.fallback.Wait(attemptNum, resp, err)
+	}
+
+	if rb.max > 0 && delay > rb.max {
+		delay = rb.max
+	}
+	return delay
+}
+
+// WithMaxDelay returns a backoff which limits the delays of backoff by max.
+func WithMaxDelay(backoff Backoff, max time.Duration) Backoff {
+	return &maxDelayBackoff{
+		backoff: backoff,
+		max:     max,
+	}
+}
+
+// Wait implements Backoff interface.
+func (mb *maxDelayBackoff) Wait(attemptNum int, resp *Response, err error) time.Duration {
+	delay := mb.backoff.Wait(attemptNum, resp, err)
+	if delay > mb.max {
+		return mb.max
+	}
+	return delay
+}
+
+// WithMinDelay returns a backoff which waits at least min whatever the delays of backoff are.
+func WithMinDelay(backoff Backoff, min time.Duration) Backoff {
+	return &minDelayBackoff{
+		backoff: backoff,
+		min:     min,
+	}
+}
+
+// Wait implements Backoff interface.
+func (mb *minDelayBackoff) Wait(attemptNum int, resp *Response, err error) time.Duration {
+	delay := mb.backoff.Wait(attemptNum, resp, err)
+	if delay < mb.min {
+		return mb.min
+	}
+	return delay
+}
+
+// Sequence provides a callback for the retry policy which
+// waits for the explicit delays in order, the last one is repeated once they're used up.
+// If no delays specified, it doesn't wait at all.
+func Sequence(delays ...time.Duration) Backoff {
+	return &sequenceBackoff{delays: delays}
+}
+
+// Wait implements Backoff interface.
+func (sb *sequenceBackoff) Wait(attemptNum int, _ *Response, _ error) time.Duration {
+	switch n := len(sb.delays); {
+	case n == 0:
+		return 0
+	case attemptNum >= n:
+		return sb.delays[n-1]
+	default:
+		return sb.delays[attemptNum]
+	}
+}
+
+// Parse the delay a response asks a client to wait before retrying.
+func retryAfter(resp *Response, now time.Time) (time.Duration, bool) {
+	if resp == nil || resp.Response == nil {
+		return 0, false
+	}
+
+	if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return nonNegative(time.Duration(seconds) * time.Second), true
+		}
+		if date, err := http.ParseTime(v); err == nil {
+			return nonNegative(date.Sub(now)), true
+		}
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable &&
+		resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+
+	for _, key := range rateLimitResetHeaders {
+		v := strings.TrimSpace(resp.Header.Get(key))
+		if v == "" {
+			continue
+		}
+
+		reset, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+
+		// Values after 2001-09-09 are considered as Unix epoch seconds, otherwise delta-seconds.
+		if reset >= 1e9 {
+			return nonNegative(time.Unix(int64(reset), 0).Sub(now)), true
+		}
+		return nonNegative(time.Duration(reset * float64(time.Second))), true
+	}
+
+	return 0, false
+}
+
+// Return baseInterval * 2^attemptNum, limited by maxInterval.
+func exponential(baseInterval, maxInterval time.Duration, attemptNum int) time.Duration {
+	return time.Duration(math.Min(float64(maxInterval), float64(baseInterval)*math.Exp2(float64(attemptNum))))
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
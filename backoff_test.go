@@ -1,6 +1,9 @@
 package ghttp
 
 import (
+	"net/http"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,6 +46,131 @@ func TestExponentialBackoff_Wait(t *testing.T) {
 	}
 }
 
+func TestEqualJitterBackoff_Wait(t *testing.T) {
+	const (
+		baseInterval = 1 * time.Second
+		maxInterval  = 30 * time.Second
+	)
+
+	backoff := NewEqualJitterBackoff(baseInterval, maxInterval)
+	for i := 0; i < 10; i++ {
+		temp := baseInterval << uint(i)
+		if temp > maxInterval {
+			temp = maxInterval
+		}
+		delay := backoff.Wait(i, nil, nil)
+		assert.True(t, delay >= temp/2)
+		assert.True(t, delay <= temp)
+	}
+}
+
+func TestDecorrelatedJitterBackoff_Wait(t *testing.T) {
+	const (
+		baseInterval = 1 * time.Second
+		maxInterval  = 30 * time.Second
+	)
+
+	backoff := NewDecorrelatedJitterBackoff(baseInterval, maxInterval)
+	limit := baseInterval
+	for i := 0; i < 10; i++ {
+		limit *= 3
+		delay := backoff.Wait(i, nil, nil)
+		assert.True(t, delay >= baseInterval)
+		assert.True(t, delay <= limit)
+		assert.True(t, delay <= maxInterval)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(attemptNum int) {
+			defer wg.Done()
+			delay := backoff.Wait(attemptNum, nil, nil)
+			assert.True(t, delay >= baseInterval)
+			assert.True(t, delay <= maxInterval)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLinearBackoff_Wait(t *testing.T) {
+	nums := []time.Duration{1, 2, 3, 4, 5, 5}
+	backoff := NewLinearBackoff(time.Second, 5*time.Second, false)
+	for i, v := range nums {
+		assert.Equal(t, v*time.Second, backoff.Wait(i, nil, nil))
+	}
+
+	backoff = NewLinearBackoff(time.Second, 0, true)
+	for i := 0; i < 10; i++ {
+		delay := backoff.Wait(i, nil, nil)
+		interval := time.Duration(i+1) * time.Second
+		assert.True(t, delay >= interval/2)
+		assert.True(t, delay <= interval/2+interval)
+	}
+}
+
+func TestWithBackoffSeed(t *testing.T) {
+	const (
+		baseInterval = 1 * time.Second
+		maxInterval  = 30 * time.Second
+	)
+
+	newBackoffs := func() []Backoff {
+		return []Backoff{
+			NewConstantBackoff(baseInterval, true, WithBackoffSeed(1)),
+			NewExponentialBackoff(baseInterval, maxInterval, true, WithBackoffSeed(1)),
+			NewEqualJitterBackoff(baseInterval, maxInterval, WithBackoffSeed(1)),
+			NewDecorrelatedJitterBackoff(baseInterval, maxInterval, WithBackoffSeed(1)),
+			NewLinearBackoff(baseInterval, maxInterval, true, WithBackoffSeed(1)),
+		}
+	}
+
+	wait := func(backoff Backoff) []time.Duration {
+		delays := make([]time.Duration, 5)
+		for i := range delays {
+			delays[i] = backoff.Wait(i, nil, nil)
+		}
+		return delays
+	}
+
+	for i, backoff := range newBackoffs() {
+		assert.Equal(t, wait(backoff), wait(newBackoffs()[i]))
+	}
+}
+
+func TestBackoffFunc_Wait(t *testing.T) {
+	backoff := BackoffFunc(func(attemptNum int, _ *Response, _ error) time.Duration {
+		return time.Duration(attemptNum) * time.Second
+	})
+	assert.Equal(t, 3*time.Second, backoff.Wait(3, nil, nil))
+}
+
+func TestWithMaxDelay(t *testing.T) {
+	backoff := WithMaxDelay(NewLinearBackoff(time.Second, 0, false), 3*time.Second)
+	nums := []time.Duration{1, 2, 3, 3}
+	for i, v := range nums {
+		assert.Equal(t, v*time.Second, backoff.Wait(i, nil, nil))
+	}
+}
+
+func TestWithMinDelay(t *testing.T) {
+	backoff := WithMinDelay(NewLinearBackoff(time.Second, 0, false), 3*time.Second)
+	nums := []time.Duration{3, 3, 3, 4}
+	for i, v := range nums {
+		assert.Equal(t, v*time.Second, backoff.Wait(i, nil, nil))
+	}
+}
+
+func TestSequence(t *testing.T) {
+	backoff := Sequence(time.Second, 5*time.Second, 10*time.Second)
+	nums := []time.Duration{1, 5, 10, 10}
+	for i, v := range nums {
+		assert.Equal(t, v*time.Second, backoff.Wait(i, nil, nil))
+	}
+
+	assert.Zero(t, Sequence().Wait(0, nil, nil))
+}
+
 func TestFibonacciBackoff_Wait(t *testing.T) {
 	nums := []time.Duration{1, 1, 2, 3, 5, 8, 13, 21, 34, 55}
 	backoff := NewFibonacciBackoff(0, time.Second)
@@ -56,3 +184,60 @@ func TestFibonacciBackoff_Wait(t *testing.T) {
 		assert.True(t, backoff.Wait(i, nil, nil) == v*time.Second)
 	}
 }
+
+func TestRetryAfterBackoff_Wait(t *testing.T) {
+	const (
+		fallback = 100 * time.Millisecond
+		max      = 2 * time.Minute
+	)
+
+	newResponse := func(statusCode int, header http.Header) *Response {
+		return &Response{
+			Response: &http.Response{
+				StatusCode: statusCode,
+				Header:     header,
+			},
+		}
+	}
+
+	backoff := NewRetryAfterBackoff(NewConstantBackoff(fallback, false), max)
+	assert.Equal(t, fallback, backoff.Wait(0, nil, errAccessDummyBody))
+	assert.Equal(t, fallback, backoff.Wait(0, &Response{}, errAccessDummyBody))
+	assert.Equal(t, fallback, backoff.Wait(0, newResponse(http.StatusTooManyRequests, http.Header{}), nil))
+
+	resp := newResponse(http.StatusTooManyRequests, http.Header{"Retry-After": {"120"}})
+	assert.Equal(t, 120*time.Second, backoff.Wait(0, resp, nil))
+
+	resp = newResponse(http.StatusServiceUnavailable, http.Header{"Retry-After": {"600"}})
+	assert.Equal(t, max, backoff.Wait(0, resp, nil))
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	resp = newResponse(http.StatusServiceUnavailable, http.Header{"Retry-After": {date}})
+	delay := backoff.Wait(0, resp, nil)
+	assert.True(t, delay > 58*time.Second && delay <= time.Minute)
+
+	date = time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	resp = newResponse(http.StatusServiceUnavailable, http.Header{"Retry-After": {date}})
+	assert.Equal(t, time.Duration(0), backoff.Wait(0, resp, nil))
+
+	resp = newResponse(http.StatusServiceUnavailable, http.Header{"Retry-After": {"soon"}})
+	assert.Equal(t, fallback, backoff.Wait(0, resp, nil))
+
+	resp = newResponse(http.StatusTooManyRequests, http.Header{"Ratelimit-Reset": {"30"}})
+	assert.Equal(t, 30*time.Second, backoff.Wait(0, resp, nil))
+
+	reset := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	resp = newResponse(http.StatusForbidden, http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {reset},
+	})
+	delay = backoff.Wait(0, resp, nil)
+	assert.True(t, delay > 58*time.Second && delay <= time.Minute)
+
+	resp = newResponse(http.StatusForbidden, http.Header{"X-Ratelimit-Reset": {reset}})
+	assert.Equal(t, fallback, backoff.Wait(0, resp, nil))
+
+	backoff = NewRetryAfterBackoff(NewConstantBackoff(fallback, false), 0)
+	resp = newResponse(http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}})
+	assert.Equal(t, time.Hour, backoff.Wait(0, resp, nil))
+}
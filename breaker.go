@@ -0,0 +1,272 @@
+package ghttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerSuccessThreshold = 1
+	defaultBreakerCoolDown         = 30 * time.Second
+)
+
+// States of a circuit breaker.
+const (
+	// CircuitClosed means requests are allowed to pass through.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen means requests fail fast with ErrCircuitOpen.
+	CircuitOpen
+
+	// CircuitHalfOpen means a limited number of trial requests are allowed
+	// to find out whether the host has recovered.
+	CircuitHalfOpen
+)
+
+var (
+	// ErrCircuitOpen is returned when a request is rejected by an open circuit breaker.
+	// Use errors.Is to check it, the actual error is a *CircuitOpenError,
+	// which isn't wrapped in a *RetryError even if retrier is enabled.
+	ErrCircuitOpen = errors.New("ghttp: circuit breaker is open")
+)
+
+type (
+	// CircuitState is the state of a circuit breaker.
+	CircuitState int
+
+	// CircuitOpenError records the circuit which rejects a request.
+	CircuitOpenError struct {
+		// Key is the circuit key of the rejected request, by default is the URL host.
+		Key string
+
+		// State is the state of the circuit when the request is rejected.
+		State CircuitState
+	}
+
+	circuitBreaker struct {
+		failureThreshold int
+		successThreshold int
+		coolDown         time.Duration
+		keyFunc          func(req *Request) string
+		failureCondition func(resp *Response, err error) bool
+		onStateChange    func(key string, from CircuitState, to CircuitState)
+
+		mu       sync.Mutex
+		circuits map[string]*circuit
+	}
+
+	circuit struct {
+		state     CircuitState
+		failures  int
+		successes int
+		probes    int
+		openedAt  time.Time
+
+		// The number of times the circuit is half-opened, which identifies the trial requests.
+		round uint64
+	}
+
+	// CircuitBreakerOption configures a circuit breaker.
+	CircuitBreakerOption func(cb *circuitBreaker)
+)
+
+// String implements fmt.Stringer interface.
+func (cs CircuitState) String() string {
+	switch cs {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Error implements error interface.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", ErrCircuitOpen.Error(), e.Key, e.State)
+}
+
+// Is reports whether target is ErrCircuitOpen, used by errors.Is.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+func defaultCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: defaultBreakerFailureThreshold,
+		successThreshold: defaultBreakerSuccessThreshold,
+		coolDown:         defaultBreakerCoolDown,
+		keyFunc: func(req *Request) string {
+			return req.URL.Host
+		},
+		failureCondition: func(resp *Response, err error) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		},
+		circuits: make(map[string]*circuit),
+	}
+}
+
+func (cb *circuitBreaker) circuit(key string) *circuit {
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[key] = c
+	}
+	return c
+}
+
+// Must be called with cb.mu held, the returned function notifies the state change
+// and must be called after cb.mu released.
+func (cb *circuitBreaker) setState(key string, c *circuit, state CircuitState) func() {
+	from := c.state
+	c.state = state
+	c.failures, c.successes, c.probes = 0, 0, 0
+	switch state {
+	case CircuitOpen:
+		c.openedAt = time.Now()
+	case CircuitHalfOpen:
+		c.round++
+	}
+
+	if cb.onStateChange == nil || from == state {
+		return func() {}
+	}
+	return func() {
+		cb.onStateChange(key, from, state)
+	}
+}
+
+// Report whether a request is allowed to be sent, if not, return a *CircuitOpenError.
+// If the request is allowed as a trial request of a half-open circuit, the round of the circuit
+// is returned as the probe, which must be passed to done, otherwise the probe is 0.
+func (cb *circuitBreaker) allow(req *Request) (uint64, error) {
+	key := cb.keyFunc(req)
+	notify := func() {}
+	defer func() { notify() }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c := cb.circuit(key)
+	if c.state == CircuitOpen {
+		if time.Since(c.openedAt) < cb.coolDown {
+			return 0, &CircuitOpenError{Key: key, State: c.state}
+		}
+		notify = cb.setState(key, c, CircuitHalfOpen)
+	}
+
+	if c.state == CircuitHalfOpen {
+		if c.probes >= cb.successThreshold {
+			return 0, &CircuitOpenError{Key: key, State: c.state}
+		}
+		c.probes++
+		return c.round, nil
+	}
+	return 0, nil
+}
+
+// Record the outcome of a request allowed by cb, probe is the one returned by allow.
+func (cb *circuitBreaker) done(req *Request, probe uint64, resp *Response, err error) {
+	key := cb.keyFunc(req)
+	failed := cb.failureCondition(resp, err)
+	notify := func() {}
+	defer func() { notify() }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c := cb.circuit(key)
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= cb.failureThreshold {
+			notify = cb.setState(key, c, CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		// Only the trial requests of the current round decide the state,
+		// the ones allowed before don't take up the trial slots
+		if probe != c.round {
+			return
+		}
+		c.probes--
+		if failed {
+			notify = cb.setState(key, c, CircuitOpen)
+			return
+		}
+		c.successes++
+		if c.successes >= cb.successThreshold {
+			notify = cb.setState(key, c, CircuitClosed)
+		}
+	}
+}
+
+// Report whether the circuit of a request is open.
+func (cb *circuitBreaker) isOpen(req *Request) bool {
+	key := cb.keyFunc(req)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.circuit(key).state == CircuitOpen
+}
+
+// WithCircuitBreakerFailureThreshold is a circuit breaker option that specifies the number of
+// consecutive failures to open a circuit.
+// By default is 5.
+func WithCircuitBreakerFailureThreshold(n int) CircuitBreakerOption {
+	return func(cb *circuitBreaker) {
+		cb.failureThreshold = n
+	}
+}
+
+// WithCircuitBreakerSuccessThreshold is a circuit breaker option that specifies the number of
+// consecutive successful trial requests to close a half-open circuit,
+// it's also the max number of concurrent trial requests.
+// By default is 1.
+func WithCircuitBreakerSuccessThreshold(n int) CircuitBreakerOption {
+	return func(cb *circuitBreaker) {
+		cb.successThreshold = n
+	}
+}
+
+// WithCircuitBreakerCoolDown is a circuit breaker option that specifies how long an open circuit
+// stays before becoming half-open.
+// By default is 30s.
+func WithCircuitBreakerCoolDown(d time.Duration) CircuitBreakerOption {
+	return func(cb *circuitBreaker) {
+		cb.coolDown = d
+	}
+}
+
+// WithCircuitBreakerKeyFunc is a circuit breaker option that specifies the function
+// to group requests into circuits.
+// By default is the URL host of a request.
+func WithCircuitBreakerKeyFunc(keyFunc func(req *Request) string) CircuitBreakerOption {
+	return func(cb *circuitBreaker) {
+		cb.keyFunc = keyFunc
+	}
+}
+
+// WithCircuitBreakerFailureCondition is a circuit breaker option that specifies the condition
+// for determining whether a request fails.
+// By default is the error isn't nil or the response status code is 5xx.
+func WithCircuitBreakerFailureCondition(condition func(resp *Response, err error) bool) CircuitBreakerOption {
+	return func(cb *circuitBreaker) {
+		cb.failureCondition = condition
+	}
+}
+
+// WithCircuitBreakerStateChange is a circuit breaker option that specifies a callback
+// which is called whenever a circuit changes its state.
+func WithCircuitBreakerStateChange(callback func(key string, from CircuitState, to CircuitState)) CircuitBreakerOption {
+	return func(cb *circuitBreaker) {
+		cb.onStateChange = callback
+	}
+}
@@ -0,0 +1,144 @@
+package ghttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitState_String(t *testing.T) {
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+	assert.Equal(t, "unknown", CircuitState(-1).String())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const coolDown = 50 * time.Millisecond
+
+	var transitions []string
+	cb := defaultCircuitBreaker()
+	opts := []CircuitBreakerOption{
+		WithCircuitBreakerFailureThreshold(2),
+		WithCircuitBreakerSuccessThreshold(1),
+		WithCircuitBreakerCoolDown(coolDown),
+		WithCircuitBreakerStateChange(func(key string, from CircuitState, to CircuitState) {
+			transitions = append(transitions, key+": "+from.String()+" -> "+to.String())
+		}),
+	}
+	for _, opt := range opts {
+		opt(cb)
+	}
+
+	req, _ := NewRequest(MethodGet, "https://httpbin.org/get")
+	ok := &Response{Response: &http.Response{StatusCode: http.StatusOK}}
+	failed := &Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}
+
+	allow := func() uint64 {
+		probe, err := cb.allow(req)
+		require.NoError(t, err)
+		return probe
+	}
+	rejected := func() bool {
+		_, err := cb.allow(req)
+		return errors.Is(err, ErrCircuitOpen)
+	}
+
+	assert.Zero(t, allow())
+	cb.done(req, 0, failed, nil)
+	// The request is still in flight when the circuit opens
+	inflight := allow()
+	assert.Zero(t, allow())
+	cb.done(req, 0, nil, errAccessDummyBody)
+	assert.True(t, cb.isOpen(req))
+
+	_, err := cb.allow(req)
+	if assert.True(t, errors.Is(err, ErrCircuitOpen)) {
+		var coe *CircuitOpenError
+		if assert.True(t, errors.As(err, &coe)) {
+			assert.Equal(t, "httpbin.org", coe.Key)
+			assert.Equal(t, CircuitOpen, coe.State)
+		}
+	}
+
+	time.Sleep(coolDown)
+	probe := allow()
+	assert.NotZero(t, probe)
+	// Only one trial request is allowed in half-open state
+	assert.True(t, rejected())
+	// The request allowed before neither frees the trial slot nor closes the circuit
+	cb.done(req, inflight, ok, nil)
+	assert.True(t, rejected())
+	cb.done(req, probe, failed, nil)
+	assert.True(t, cb.isOpen(req))
+
+	time.Sleep(coolDown)
+	last := probe
+	probe = allow()
+	// Neither does the trial request of the last round
+	cb.done(req, last, nil, errAccessDummyBody)
+	assert.True(t, rejected())
+	cb.done(req, probe, ok, nil)
+	assert.False(t, cb.isOpen(req))
+
+	assert.Equal(t, []string{
+		"httpbin.org: closed -> open",
+		"httpbin.org: open -> half-open",
+		"httpbin.org: half-open -> open",
+		"httpbin.org: open -> half-open",
+		"httpbin.org: half-open -> closed",
+	}, transitions)
+}
+
+func TestClient_EnableCircuitBreaker(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&counter, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.EnableCircuitBreaker(
+		WithCircuitBreakerFailureThreshold(2),
+		WithCircuitBreakerKeyFunc(func(req *Request) string {
+			return req.URL.Path
+		}),
+	)
+
+	resp, err := client.Get(ts.URL+"/down",
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode != http.StatusOK
+			}),
+		),
+	)
+	// Retries stop once the circuit opens
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&counter))
+
+	_, err = client.Get(ts.URL + "/down")
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&counter))
+
+	_, err = client.Get(ts.URL+"/down", WithRetrier())
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	var coe *CircuitOpenError
+	assert.True(t, errors.As(err, &coe))
+	var retryErr *RetryError
+	assert.False(t, errors.As(err, &retryErr))
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&counter))
+
+	_, err = client.Get(ts.URL + "/other")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&counter))
+}
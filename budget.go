@@ -0,0 +1,79 @@
+package ghttp
+
+import "sync"
+
+type (
+	// RetryBudgetStats reports the state of a Client's retry budget.
+	RetryBudgetStats struct {
+		// Tokens is the number of tokens currently in the bucket.
+		Tokens float64 `json:"tokens"`
+
+		// Retries is the number of retries allowed by the budget.
+		Retries uint64 `json:"retries"`
+
+		// Denied is the number of retries denied by the budget.
+		Denied uint64 `json:"denied"`
+	}
+
+	// A token bucket shared by all requests of a Client, which works the same as
+	// the retry throttling of gRPC.
+	// See: https://github.com/grpc/proposal/blob/master/A6-client-retries.md#throttling-retry-attempts-and-hedged-rpcs
+	retryBudget struct {
+		mu         sync.Mutex
+		maxTokens  float64
+		tokenRatio float64
+		tokens     float64
+		retries    uint64
+		denied     uint64
+	}
+)
+
+func newRetryBudget(maxTokens int, tokenRatio float64) *retryBudget {
+	return &retryBudget{
+		maxTokens:  float64(maxTokens),
+		tokenRatio: tokenRatio,
+		tokens:     float64(maxTokens),
+	}
+}
+
+// Record the outcome of an attempt. A failed attempt takes one token from the bucket,
+// while a successful one puts tokenRatio tokens back.
+func (rb *retryBudget) record(failed bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if failed {
+		rb.tokens--
+		if rb.tokens < 0 {
+			rb.tokens = 0
+		}
+		return
+	}
+
+	rb.tokens += rb.tokenRatio
+	if rb.tokens > rb.maxTokens {
+		rb.tokens = rb.maxTokens
+	}
+}
+
+// Report whether a retry is allowed, that is, the bucket is more than half full.
+func (rb *retryBudget) allow() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.tokens > rb.maxTokens/2 {
+		rb.retries++
+		return true
+	}
+
+	rb.denied++
+	return false
+}
+
+func (rb *retryBudget) stats() RetryBudgetStats {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return RetryBudgetStats{
+		Tokens:  rb.tokens,
+		Retries: rb.retries,
+		Denied:  rb.denied,
+	}
+}
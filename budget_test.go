@@ -0,0 +1,89 @@
+package ghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	rb := newRetryBudget(4, 0.5)
+	assert.True(t, rb.allow())
+
+	rb.record(true)
+	rb.record(true)
+	assert.False(t, rb.allow())
+
+	rb.record(false)
+	assert.True(t, rb.allow())
+
+	for i := 0; i < 10; i++ {
+		rb.record(true)
+	}
+	assert.Equal(t, RetryBudgetStats{Tokens: 0, Retries: 2, Denied: 1}, rb.stats())
+
+	for i := 0; i < 10; i++ {
+		rb.record(false)
+	}
+	assert.Equal(t, float64(4), rb.stats().Tokens)
+}
+
+func TestClient_EnableRetryBudget(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&counter, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := New()
+	assert.Zero(t, client.RetryBudgetStats())
+
+	client.EnableRetryBudget(4, 0.1)
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(ts.URL,
+			WithRetrier(
+				WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			),
+		)
+		assert.NoError(t, err)
+	}
+
+	// tokens: 4 -> 3 (retry) -> 2 (denied), then 2 -> 1 (denied)
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&counter))
+	stats := client.RetryBudgetStats()
+	assert.Equal(t, uint64(1), stats.Retries)
+	assert.Equal(t, uint64(2), stats.Denied)
+	assert.Equal(t, float64(1), stats.Tokens)
+}
+
+func TestClient_RetryBudgetWithoutRetrier(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&counter, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.EnableRetryBudget(4, 0.1)
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(ts.URL)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, float64(2), client.RetryBudgetStats().Tokens)
+
+	// The failures of the requests without retrier drain the budget too
+	_, err := client.Get(ts.URL,
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+		),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&counter))
+	assert.Equal(t, RetryBudgetStats{Tokens: 1, Retries: 0, Denied: 1}, client.RetryBudgetStats())
+}
@@ -0,0 +1,150 @@
+package ghttp
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type (
+	// Cache is the interface that defines a storage for cached responses.
+	// Implementations must be safe for concurrent use by multiple goroutines.
+	Cache interface {
+		// Get returns the value stored for key and reports whether it's found.
+		Get(key string) ([]byte, bool)
+
+		// Set stores value for key.
+		Set(key string, value []byte)
+
+		// Delete removes the value stored for key.
+		Delete(key string)
+	}
+
+	memoryCache struct {
+		mu       sync.Mutex
+		capacity int
+		ll       *list.List
+		items    map[string]*list.Element
+	}
+
+	memoryCacheItem struct {
+		key   string
+		value []byte
+	}
+
+	diskCache struct {
+		dir string
+		mu  sync.RWMutex
+	}
+)
+
+// NewMemoryCache returns an in-memory LRU cache that holds up to capacity entries.
+// If capacity less than or equal to zero, it means no limit.
+func NewMemoryCache(capacity int) Cache {
+	return &memoryCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements Cache interface.
+func (mc *memoryCache) Get(key string) ([]byte, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if e, ok := mc.items[key]; ok {
+		mc.ll.MoveToFront(e)
+		return e.Value.(*memoryCacheItem).value, true
+	}
+
+	return nil, false
+}
+
+// Set implements Cache interface.
+func (mc *memoryCache) Set(key string, value []byte) {
+	// The caller may reuse value after Set returns
+	value = append([]byte(nil), value...)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if e, ok := mc.items[key]; ok {
+		mc.ll.MoveToFront(e)
+		e.Value.(*memoryCacheItem).value = value
+		return
+	}
+
+	mc.items[key] = mc.ll.PushFront(&memoryCacheItem{key: key, value: value})
+	if mc.capacity > 0 && mc.ll.Len() > mc.capacity {
+		e := mc.ll.Back()
+		mc.ll.Remove(e)
+		delete(mc.items, e.Value.(*memoryCacheItem).key)
+	}
+}
+
+// Delete implements Cache interface.
+func (mc *memoryCache) Delete(key string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if e, ok := mc.items[key]; ok {
+		mc.ll.Remove(e)
+		delete(mc.items, key)
+	}
+}
+
+// NewDiskCache returns a cache that stores entries as files under dir.
+// The directory is created if it doesn't exist.
+func NewDiskCache(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &diskCache{dir: dir}, nil
+}
+
+func (dc *diskCache) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dc.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements Cache interface.
+func (dc *diskCache) Get(key string) ([]byte, bool) {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+	value, err := ioutil.ReadFile(dc.filename(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+// Set implements Cache interface.
+func (dc *diskCache) Set(key string, value []byte) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	file, err := ioutil.TempFile(dc.dir, "tmp-")
+	if err != nil {
+		return
+	}
+
+	_, err = file.Write(value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), dc.filename(key))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+}
+
+// Delete implements Cache interface.
+func (dc *diskCache) Delete(key string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	os.Remove(dc.filename(key))
+}
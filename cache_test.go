@@ -0,0 +1,69 @@
+package ghttp
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("k1", []byte("v1"))
+	cache.Set("k2", []byte("v2"))
+
+	v, ok := cache.Get("k1")
+	if assert.True(t, ok) {
+		assert.Equal(t, []byte("v1"), v)
+	}
+
+	// k2 is the least recently used one now
+	cache.Set("k3", []byte("v3"))
+	_, ok = cache.Get("k2")
+	assert.False(t, ok)
+
+	cache.Set("k1", []byte("v1.1"))
+	v, ok = cache.Get("k1")
+	if assert.True(t, ok) {
+		assert.Equal(t, []byte("v1.1"), v)
+	}
+
+	cache.Delete("k1")
+	_, ok = cache.Get("k1")
+	assert.False(t, ok)
+
+	value := []byte("v4")
+	cache.Set("k4", value)
+	copy(value, "xx")
+	v, ok = cache.Get("k4")
+	if assert.True(t, ok) {
+		assert.Equal(t, []byte("v4"), v)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghttp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache, err := NewDiskCache(dir)
+	require.NoError(t, err)
+
+	_, ok := cache.Get("k1")
+	assert.False(t, ok)
+
+	cache.Set("k1", []byte("v1"))
+	v, ok := cache.Get("k1")
+	if assert.True(t, ok) {
+		assert.Equal(t, []byte("v1"), v)
+	}
+
+	cache.Delete("k1")
+	_, ok = cache.Get("k1")
+	assert.False(t, ok)
+
+	_, err = NewDiskCache(dir + "/testfile\x00")
+	assert.Error(t, err)
+}
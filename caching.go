@@ -0,0 +1,497 @@
+package ghttp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/winterssy/bufferpool"
+	"github.com/winterssy/gjson"
+)
+
+// Cache statuses of a response.
+const (
+	// CacheMiss means the response was fetched from the origin server.
+	CacheMiss CacheStatus = iota
+
+	// CacheHit means the response was served from cache without contacting the origin server.
+	CacheHit
+
+	// CacheRevalidated means the response was served from cache after the origin server
+	// validated it with a 304 Not Modified.
+	CacheRevalidated
+
+	// CacheStale means a stale response was served from cache while it's being
+	// revalidated in background, see the stale-while-revalidate directive.
+	CacheStale
+)
+
+var (
+	// Status codes that are defined as cacheable by default, see RFC 7231 Section 6.1.
+	cacheableStatusCodes = map[int]bool{
+		http.StatusOK:                   true,
+		http.StatusNonAuthoritativeInfo: true,
+		http.StatusNoContent:            true,
+		http.StatusMultipleChoices:      true,
+		http.StatusMovedPermanently:     true,
+		http.StatusNotFound:             true,
+		http.StatusMethodNotAllowed:     true,
+		http.StatusGone:                 true,
+		http.StatusRequestURITooLong:    true,
+		http.StatusNotImplemented:       true,
+	}
+)
+
+type (
+	// CacheStatus reports how a response was obtained when caching is enabled.
+	CacheStatus int
+
+	cacher struct {
+		cache        Cache
+		revalidating sync.Map
+	}
+
+	cacheEntry struct {
+		Status        string      `json:"status"`
+		StatusCode    int         `json:"status_code"`
+		Proto         string      `json:"proto"`
+		ProtoMajor    int         `json:"proto_major"`
+		ProtoMinor    int         `json:"proto_minor"`
+		Header        http.Header `json:"header"`
+		Body          []byte      `json:"body"`
+		RequestHeader http.Header `json:"request_header,omitempty"`
+		RequestTime   time.Time   `json:"request_time"`
+		ResponseTime  time.Time   `json:"response_time"`
+	}
+
+	cacheControl map[string]string
+)
+
+// String implements fmt.Stringer interface.
+func (cs CacheStatus) String() string {
+	switch cs {
+	case CacheMiss:
+		return "MISS"
+	case CacheHit:
+		return "HIT"
+	case CacheRevalidated:
+		return "REVALIDATED"
+	case CacheStale:
+		return "STALE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h["Cache-Control"] {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+
+			var value string
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				directive, value = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(directive))] = value
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func (cc cacheControl) duration(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+func cacheKey(req *Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+func newCacher(cache Cache) *cacher {
+	return &cacher{cache: cache}
+}
+
+func (c *cacher) wrap(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		switch req.Method {
+		case MethodGet, MethodHead:
+		default:
+			resp, err := next(req)
+			// Unsafe methods invalidate the stored responses, see RFC 7234 Section 4.4.
+			if err == nil && req.Method != MethodOptions && req.Method != MethodTrace &&
+				resp.StatusCode < http.StatusBadRequest {
+				c.cache.Delete(MethodGet + " " + req.URL.String())
+				c.cache.Delete(MethodHead + " " + req.URL.String())
+			}
+			return resp, err
+		}
+
+		reqCC := parseCacheControl(req.Header)
+		if reqCC.has("no-store") ||
+			req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+			return next(req)
+		}
+
+		key := cacheKey(req)
+		entry := c.load(key)
+		if entry != nil && !entry.matchVary(req) {
+			entry = nil
+		}
+
+		if entry == nil {
+			if reqCC.has("only-if-cached") {
+				return gatewayTimeout(req), nil
+			}
+
+			return c.fetch(next, req, key)
+		}
+
+		now := time.Now()
+		respCC := parseCacheControl(entry.Header)
+		age := entry.age(now)
+		lifetime := entry.freshnessLifetime(respCC)
+		if maxAge, ok := reqCC.duration("max-age"); ok && maxAge < lifetime {
+			lifetime = maxAge
+		}
+		if minFresh, ok := reqCC.duration("min-fresh"); ok {
+			age += minFresh
+		}
+
+		mustRevalidate := reqCC.has("no-cache") || respCC.has("no-cache")
+		if !mustRevalidate && age < lifetime {
+			return entry.toResponse(req, age, CacheHit), nil
+		}
+
+		if !mustRevalidate && !respCC.has("must-revalidate") {
+			if swr, ok := respCC.duration("stale-while-revalidate"); ok && age < lifetime+swr {
+				// Build the response first since the entry is updated by the revalidation
+				resp := entry.toResponse(req, age, CacheStale)
+				c.revalidateInBackground(next, req, key, entry)
+				return resp, nil
+			}
+			if maxStale, ok := reqCC["max-stale"]; ok {
+				limit, valid := reqCC.duration("max-stale")
+				if maxStale == "" || (valid && age < lifetime+limit) {
+					return entry.toResponse(req, age, CacheStale), nil
+				}
+			}
+		}
+
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(req), nil
+		}
+
+		return c.revalidate(next, req, key, entry)
+	}
+}
+
+func (c *cacher) load(key string) *cacheEntry {
+	data, ok := c.cache.Get(key)
+	if !ok {
+		return nil
+	}
+
+	entry := new(cacheEntry)
+	if err := gjson.NewDecoder(bytes.NewReader(data)).Decode(entry); err != nil {
+		c.cache.Delete(key)
+		return nil
+	}
+
+	return entry
+}
+
+func (c *cacher) store(key string, entry *cacheEntry) {
+	data, err := encodeJSON(entry)
+	if err == nil {
+		c.cache.Set(key, data)
+	}
+}
+
+func (c *cacher) fetch(next Handler, req *Request, key string) (*Response, error) {
+	requestTime := time.Now()
+	resp, err := next(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if !cacheable(req, resp) {
+		c.cache.Delete(key)
+		return resp, nil
+	}
+
+	entry, err := newCacheEntry(req, resp, requestTime)
+	if err != nil {
+		return resp, err
+	}
+
+	c.store(key, entry)
+	return resp, nil
+}
+
+func (c *cacher) revalidate(next Handler, req *Request, key string, entry *cacheEntry) (*Response, error) {
+	etag := entry.Header.Get("ETag")
+	lastModified := entry.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return c.fetch(next, req, key)
+	}
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	requestTime := time.Now()
+	resp, err := next(req)
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		if !cacheable(req, resp) {
+			c.cache.Delete(key)
+			return resp, nil
+		}
+
+		var fresh *cacheEntry
+		if fresh, err = newCacheEntry(req, resp, requestTime); err == nil {
+			c.store(key, fresh)
+		}
+		return resp, err
+	}
+
+	drainBody(resp.Body, ioutil.Discard)
+	entry.update(resp, requestTime)
+	c.store(key, entry)
+	return entry.toResponse(req, 0, CacheRevalidated), nil
+}
+
+func (c *cacher) revalidateInBackground(next Handler, req *Request, key string, entry *cacheEntry) {
+	if _, loaded := c.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+
+	bgReq := &Request{
+		Request: req.Request.Clone(context.Background()),
+		retrier: req.retrier,
+	}
+	go func() {
+		defer c.revalidating.Delete(key)
+		resp, err := c.revalidate(next, bgReq, key, entry)
+		if err == nil && !bodyEmpty(resp.Body) {
+			drainBody(resp.Body, ioutil.Discard)
+		}
+	}()
+}
+
+// Report whether a response is allowed to be stored, see RFC 7234 Section 3.
+func cacheable(req *Request, resp *Response) bool {
+	if !cacheableStatusCodes[resp.StatusCode] {
+		return false
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	respCC := parseCacheControl(resp.Header)
+	if reqCC.has("no-store") || respCC.has("no-store") {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" && !respCC.has("public") &&
+		!respCC.has("must-revalidate") && !respCC.has("s-maxage") {
+		return false
+	}
+	for _, field := range headerValues(resp.Header, "Vary") {
+		if field == "*" {
+			return false
+		}
+	}
+
+	return respCC.has("max-age") || respCC.has("no-cache") || respCC.has("public") ||
+		resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func gatewayTimeout(req *Request) *Response {
+	return &Response{
+		Response: &http.Response{
+			Status:     "504 " + http.StatusText(http.StatusGatewayTimeout),
+			StatusCode: http.StatusGatewayTimeout,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req.Request,
+		},
+	}
+}
+
+func headerValues(h http.Header, key string) []string {
+	var values []string
+	for _, v := range h[http.CanonicalHeaderKey(key)] {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				values = append(values, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+	return values
+}
+
+func newCacheEntry(req *Request, resp *Response, requestTime time.Time) (*cacheEntry, error) {
+	buf := bufferpool.Get()
+	defer buf.Free()
+	if !bodyEmpty(resp.Body) {
+		if err := drainBody(resp.Body, buf); err != nil {
+			return nil, err
+		}
+	}
+	body := make([]byte, buf.Len())
+	copy(body, buf.Bytes())
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry := &cacheEntry{
+		Status:       resp.Status,
+		StatusCode:   resp.StatusCode,
+		Proto:        resp.Proto,
+		ProtoMajor:   resp.ProtoMajor,
+		ProtoMinor:   resp.ProtoMinor,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+	}
+	if fields := headerValues(resp.Header, "Vary"); len(fields) > 0 {
+		entry.RequestHeader = make(http.Header, len(fields))
+		for _, field := range fields {
+			entry.RequestHeader[field] = req.Header[field]
+		}
+	}
+	return entry, nil
+}
+
+// Report whether req matches the request headers nominated by the stored response, see RFC 7234 Section 4.1.
+func (e *cacheEntry) matchVary(req *Request) bool {
+	for _, field := range headerValues(e.Header, "Vary") {
+		if field == "*" {
+			return false
+		}
+		if strings.Join(e.RequestHeader[field], ",") != strings.Join(req.Header[field], ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// Calculate the current age of the stored response, see RFC 7234 Section 4.2.3.
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	date := e.date()
+	apparentAge := e.ResponseTime.Sub(date)
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	correctedAge := e.ResponseTime.Sub(e.RequestTime)
+	if seconds, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		correctedAge += time.Duration(seconds) * time.Second
+	}
+
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+	return correctedAge + now.Sub(e.ResponseTime)
+}
+
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// Calculate the freshness lifetime of the stored response, see RFC 7234 Section 4.2.1.
+func (e *cacheEntry) freshnessLifetime(cc cacheControl) time.Duration {
+	if maxAge, ok := cc.duration("max-age"); ok {
+		return maxAge
+	}
+
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// Invalid dates, like "0", represent a time in the past.
+			return 0
+		}
+		return expires.Sub(e.date())
+	}
+
+	// Heuristic freshness, see RFC 7234 Section 4.2.2.
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		if lifetime := e.date().Sub(lastModified) / 10; lifetime > 0 {
+			return lifetime
+		}
+	}
+
+	return 0
+}
+
+// Update the stored response with a 304 response, see RFC 7234 Section 4.3.4.
+func (e *cacheEntry) update(resp *Response, requestTime time.Time) {
+	for k, vs := range resp.Header {
+		switch k {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		e.Header[k] = vs
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = time.Now()
+}
+
+func (e *cacheEntry) toResponse(req *Request, age time.Duration, status CacheStatus) *Response {
+	header := e.Header.Clone()
+	if age > 0 {
+		header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	}
+	if status == CacheStale {
+		header.Add("Warning", `110 - "Response is Stale"`)
+	}
+
+	return &Response{
+		Response: &http.Response{
+			Status:        e.Status,
+			StatusCode:    e.StatusCode,
+			Proto:         e.Proto,
+			ProtoMajor:    e.ProtoMajor,
+			ProtoMinor:    e.ProtoMinor,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+			ContentLength: int64(len(e.Body)),
+			Request:       req.Request,
+		},
+		cacheStatus: status,
+	}
+}
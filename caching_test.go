@@ -0,0 +1,183 @@
+package ghttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	h := http.Header{"Cache-Control": {`max-age=60, No-Cache, private="Set-Cookie"`, "stale-while-revalidate=30"}}
+	cc := parseCacheControl(h)
+	assert.True(t, cc.has("no-cache"))
+	assert.Equal(t, "Set-Cookie", cc["private"])
+
+	d, ok := cc.duration("max-age")
+	if assert.True(t, ok) {
+		assert.Equal(t, 60*time.Second, d)
+	}
+	_, ok = cc.duration("private")
+	assert.False(t, ok)
+	_, ok = cc.duration("min-fresh")
+	assert.False(t, ok)
+}
+
+func TestCacheStatus_String(t *testing.T) {
+	assert.Equal(t, "MISS", CacheMiss.String())
+	assert.Equal(t, "HIT", CacheHit.String())
+	assert.Equal(t, "REVALIDATED", CacheRevalidated.String())
+	assert.Equal(t, "STALE", CacheStale.String())
+	assert.Equal(t, "UNKNOWN", CacheStatus(-1).String())
+}
+
+func TestClient_EnableCaching(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&counter, 1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/stale":
+			w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		}
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.EnableCaching(NewMemoryCache(0))
+
+	get := func(path string, hooks ...RequestHook) (string, CacheStatus) {
+		resp, err := client.Get(ts.URL+path, hooks...)
+		require.NoError(t, err)
+		text, err := resp.Text()
+		require.NoError(t, err)
+		return text, resp.CacheStatus()
+	}
+
+	text, status := get("/max-age")
+	assert.Equal(t, "/max-age ", text)
+	assert.Equal(t, CacheMiss, status)
+	text, status = get("/max-age")
+	assert.Equal(t, "/max-age ", text)
+	assert.Equal(t, CacheHit, status)
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&counter))
+
+	_, status = get("/max-age", WithHeaders(Headers{"Cache-Control": "no-store"}))
+	assert.Equal(t, CacheMiss, status)
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&counter))
+
+	_, err := client.Post(ts.URL + "/max-age")
+	require.NoError(t, err)
+	_, status = get("/max-age")
+	assert.Equal(t, CacheMiss, status)
+
+	atomic.StoreUint64(&counter, 0)
+	_, status = get("/etag")
+	assert.Equal(t, CacheMiss, status)
+	text, status = get("/etag")
+	assert.Equal(t, "/etag ", text)
+	assert.Equal(t, CacheRevalidated, status)
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&counter))
+
+	_, status = get("/no-store")
+	assert.Equal(t, CacheMiss, status)
+	_, status = get("/no-store")
+	assert.Equal(t, CacheMiss, status)
+
+	text, status = get("/vary", WithHeaders(Headers{"Accept-Language": "en"}))
+	assert.Equal(t, "/vary en", text)
+	assert.Equal(t, CacheMiss, status)
+	text, status = get("/vary", WithHeaders(Headers{"Accept-Language": "en"}))
+	assert.Equal(t, "/vary en", text)
+	assert.Equal(t, CacheHit, status)
+	text, status = get("/vary", WithHeaders(Headers{"Accept-Language": "zh"}))
+	assert.Equal(t, "/vary zh", text)
+	assert.Equal(t, CacheMiss, status)
+
+	atomic.StoreUint64(&counter, 0)
+	_, status = get("/stale")
+	assert.Equal(t, CacheMiss, status)
+	_, status = get("/stale")
+	assert.Equal(t, CacheStale, status)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&counter))
+
+	resp, err := client.Get(ts.URL+"/uncached", WithHeaders(Headers{"Cache-Control": "only-if-cached"}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	}
+}
+
+func TestClient_CachingStaleWhileRevalidate(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&counter, 1)
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.EnableCaching(NewMemoryCache(0))
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		text, err := resp.Text()
+		require.NoError(t, err)
+		assert.Equal(t, "hello", text)
+		if i == 1 {
+			// The stale response is served while the entry is updated in background
+			assert.Equal(t, CacheStale, resp.CacheStatus())
+			assert.Equal(t, `110 - "Response is Stale"`, resp.Header.Get("Warning"))
+		}
+		for n := uint64(i + 1); atomic.LoadUint64(&counter) < n; {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestCacheEntry_FreshnessLifetime(t *testing.T) {
+	now := time.Now().UTC()
+	entry := &cacheEntry{
+		Header: http.Header{
+			"Date":    {now.Format(http.TimeFormat)},
+			"Expires": {now.Add(time.Hour).Format(http.TimeFormat)},
+		},
+		RequestTime:  now,
+		ResponseTime: now,
+	}
+	assert.Equal(t, time.Hour, entry.freshnessLifetime(parseCacheControl(entry.Header)))
+
+	entry.Header.Set("Expires", "0")
+	assert.Equal(t, time.Duration(0), entry.freshnessLifetime(parseCacheControl(entry.Header)))
+
+	entry.Header.Del("Expires")
+	entry.Header.Set("Last-Modified", now.Add(-10*time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, time.Hour, entry.freshnessLifetime(parseCacheControl(entry.Header)))
+
+	entry.Header.Set("Age", "30")
+	assert.True(t, entry.age(now) >= 30*time.Second)
+}
@@ -2,6 +2,7 @@ package ghttp
 
 import (
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"io"
@@ -40,8 +41,13 @@ type (
 	// Client is a wrapper around an http.Client.
 	Client struct {
 		*http.Client
+		middlewares            []Middleware
+		attemptMiddlewares     []Middleware
 		beforeRequestCallbacks []BeforeRequestCallback
 		afterResponseCallbacks []AfterResponseCallback
+		breaker                *circuitBreaker
+		budget                 *retryBudget
+		concurrency            *concurrency
 	}
 )
 
@@ -147,35 +153,139 @@ func (c *Client) Cookie(url string, name string) (*http.Cookie, error) {
 	return findCookie(name, c.Cookies(url))
 }
 
+// Use appends c's middlewares.
+// Middlewares are invoked in the order they're added, the first one is the outermost.
+// They wrap the whole request, that is, they're invoked once per Do around the retries,
+// see UseAttempt for the middlewares invoked per attempt.
+// If a middleware returns neither a response nor an error, ErrNoResponse is reported.
+func (c *Client) Use(middlewares ...Middleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// UseAttempt appends c's attempt middlewares.
+// Unlike the ones added by Use, they're invoked for every attempt inside the retry loop,
+// so they can observe and act around each attempt, e.g. refresh an expired token before a retry,
+// see Request.AttemptNum for the current attempt. A hedged attempt is wrapped as a whole.
+// They're invoked in the order they're added, the first one is the outermost.
+func (c *Client) UseAttempt(middlewares ...Middleware) {
+	c.attemptMiddlewares = append(c.attemptMiddlewares, middlewares...)
+}
+
 // RegisterBeforeRequestCallbacks appends c's before request callbacks.
+// All callbacks are invoked in the order they're registered by one middleware, which is added
+// by the first registration, see Client.Use for more details.
+// If a before request callback returns an error, the request stops and no after response callback is invoked.
 func (c *Client) RegisterBeforeRequestCallbacks(callbacks ...BeforeRequestCallback) {
+	if len(callbacks) > 0 {
+		c.useCallbacks()
+	}
 	c.beforeRequestCallbacks = append(c.beforeRequestCallbacks, callbacks...)
 }
 
-// RegisterAfterResponseCallbacks appends c's after response callbacks.
+// RegisterAfterResponseCallbacks appends c's after response callbacks,
+// see RegisterBeforeRequestCallbacks for more details.
 func (c *Client) RegisterAfterResponseCallbacks(callbacks ...AfterResponseCallback) {
+	if len(callbacks) > 0 {
+		c.useCallbacks()
+	}
 	c.afterResponseCallbacks = append(c.afterResponseCallbacks, callbacks...)
 }
 
-// EnableRateLimiting adds a callback to c for limiting outbound requests
+// Add the middleware invokes the callbacks if not yet.
+func (c *Client) useCallbacks() {
+	if len(c.beforeRequestCallbacks) == 0 && len(c.afterResponseCallbacks) == 0 {
+		c.Use(c.invokeCallbacks)
+	}
+}
+
+// EnableRateLimiting adds a middleware to c for limiting outbound requests
 // given a rate.Limiter (provided by golang.org/x/time/rate package).
 func (c *Client) EnableRateLimiting(limiter *rate.Limiter) {
-	c.RegisterBeforeRequestCallbacks(&rateLimiter{base: limiter})
+	c.Use((&rateLimiter{base: limiter}).wrap)
 }
 
-// SetMaxConcurrency adds a callback to c for limiting the concurrent outbound requests up by n.
+// SetMaxConcurrency adds a middleware to c for limiting the concurrent outbound requests up by n.
+// Extra copies sent by hedging also take up slots, they're skipped when no slot is available.
 func (c *Client) SetMaxConcurrency(n int) {
-	callback := &concurrency{ch: make(chan struct{}, n)}
-	c.RegisterBeforeRequestCallbacks(callback)
-	c.RegisterAfterResponseCallbacks(callback)
+	c.concurrency = &concurrency{ch: make(chan struct{}, n)}
+	c.Use(c.concurrency.wrap)
+}
+
+// EnableCaching adds a middleware to c for caching responses of GET and HEAD requests into cache.
+// It honors the caching semantics defined in RFC 7234, such as Cache-Control, Expires, Vary,
+// conditional revalidation using ETag or Last-Modified, and stale-while-revalidate.
+func (c *Client) EnableCaching(cache Cache) {
+	c.Use(newCacher(cache).wrap)
+}
+
+// EnableCircuitBreaker enables a circuit breaker for c, which tracks failures per host
+// and makes requests fail fast with ErrCircuitOpen while a host is considered down.
+// Every attempt made by a retrier counts and no more retries would be made once the circuit opens.
+func (c *Client) EnableCircuitBreaker(opts ...CircuitBreakerOption) {
+	breaker := defaultCircuitBreaker()
+	for _, opt := range opts {
+		opt(breaker)
+	}
+	c.breaker = breaker
+}
+
+// EnableRetryBudget enables a retry budget shared by all requests of c to prevent retry storms.
+// Every failed attempt of all requests, whether retrier enabled or not, takes one token from a bucket whose
+// capacity is maxTokens, and every successful attempt puts tokenRatio tokens back.
+// An attempt fails according to the retry triggers of the request, or if it gets an error or a 429 response
+// when retrier is disabled. Retries are allowed only if the bucket is more than half full.
+func (c *Client) EnableRetryBudget(maxTokens int, tokenRatio float64) {
+	c.budget = newRetryBudget(maxTokens, tokenRatio)
+}
+
+// RetryBudgetStats returns the stats of c's retry budget.
+// If the retry budget isn't enabled, it returns the zero value.
+func (c *Client) RetryBudgetStats() (stats RetryBudgetStats) {
+	if c.budget != nil {
+		stats = c.budget.stats()
+	}
+	return
 }
 
-// EnableDebugging adds a callback to c for debugging.
+// EnableDebugging adds a middleware to c for debugging.
 // ghttp will dump the request and response details to w, like "curl -v".
-func (c *Client) EnableDebugging(w io.Writer, body bool) {
-	callback := &debugger{out: w, body: body}
-	c.RegisterBeforeRequestCallbacks(callback)
-	c.RegisterAfterResponseCallbacks(callback)
+func (c *Client) EnableDebugging(w io.Writer, body bool, opts ...DebugOption) {
+	d := &debugger{out: w, body: body, curlOpts: []CurlOption{WithCurlClient(c)}}
+	for _, opt := range opts {
+		opt(d)
+	}
+	c.Use(d.wrap)
+}
+
+// EnableLogging adds a middleware to c for logging a structured event per request through logger.
+func (c *Client) EnableLogging(logger Logger, opts ...LogOption) {
+	c.Use(newLogging(logger, opts).wrap)
+}
+
+// EnableHARRecording adds a middleware to c for capturing the requests and responses to recorder,
+// which can be exported as a HAR document. It also enables client trace for the timings.
+func (c *Client) EnableHARRecording(recorder *HARRecorder) {
+	c.Use(recorder.wrap(c.Jar))
+}
+
+// EnableMetrics adds a middleware and an attempt middleware to c for reporting the metrics of
+// requests to metrics, such as request counts, latencies, in-flight requests, retries and bytes
+// sent and received. The latencies include the middlewares added after it.
+func (c *Client) EnableMetrics(metrics Metrics, opts ...MetricsOption) {
+	mh := newMetricsHook(metrics, opts)
+	c.Use(mh.wrap)
+	c.UseAttempt(mh.wrapAttempt)
+}
+
+// EnableTracing adds a middleware and an attempt middleware to c for starting a span per request,
+// with child spans per attempt and per DNS lookup, TCP connect and TLS handshake phase,
+// and exporting them through exporter.
+// The W3C traceparent and tracestate headers are injected to propagate the trace, the parent
+// span is taken from the request's context if set by ContextWithSpanContext.
+func (c *Client) EnableTracing(exporter SpanExporter, opts ...TracingOption) {
+	t := newTracer(exporter, opts)
+	c.Use(t.wrap)
+	c.UseAttempt(t.wrapAttempt)
 }
 
 // Get makes a GET HTTP request.
@@ -231,50 +341,95 @@ func (c *Client) Send(method string, url string, hooks ...RequestHook) (*Respons
 }
 
 // Do sends a request and returns its response.
-func (c *Client) Do(req *Request) (resp *Response, err error) {
-	if err = c.onBeforeRequest(req); err != nil {
-		return
-	}
+// The request passes through c's middlewares first, and then req's.
+// Each attempt of the request passes through c's attempt middlewares first, and then req's.
+func (c *Client) Do(req *Request) (*Response, error) {
+	return chainChecked(chainChecked(c.send, req.middlewares...), c.middlewares...)(req)
+}
 
+func (c *Client) send(req *Request) (*Response, error) {
 	if req.retrier != nil {
-		if err = req.retrier.modifyRequest(req); err != nil {
-			return
+		if err := req.retrier.modifyRequest(req); err != nil {
+			return nil, err
 		}
 	}
-
-	resp, err = c.doWithRetry(req)
-	c.onAfterResponse(resp, err)
-	return
-}
-
-func (c *Client) onBeforeRequest(req *Request) (err error) {
-	for _, callback := range c.beforeRequestCallbacks {
-		if err = callback.Enter(req); err != nil {
-			break
+	if req.hedger != nil {
+		if err := req.bufferBody(); err != nil {
+			return nil, err
 		}
 	}
-	return
+
+	return c.doWithRetry(req)
 }
 
 func (c *Client) doWithRetry(req *Request) (*Response, error) {
 	var err error
 	var sleep time.Duration
+	var wt *writeTracker
+	var attempts []Attempt
+	if req.retrier != nil {
+		wt = new(writeTracker)
+		wt.modifyRequest(req)
+	}
+	ctx := req.Context()
+	attempt := chainChecked(chainChecked(c.sendAttempt, req.attemptMiddlewares...), c.attemptMiddlewares...)
 	resp := new(Response)
 	for attemptNum := 0; ; attemptNum++ {
-		if req.clientTrace {
-			ct := &clientTrace{start: time.Now()}
-			ct.modifyRequest(req)
-			resp.clientTrace = ct
+		var probe uint64
+		if c.breaker != nil {
+			if probe, err = c.breaker.allow(req); err != nil {
+				// A request rejected before any attempt is made isn't a retry failure
+				if len(attempts) > 0 {
+					err = retryError(attempts, err)
+				}
+				return nil, err
+			}
+		}
+
+		if wt != nil {
+			wt.reset()
 		}
-		resp.Response, err = c.do(req.Request)
 		if req.clientTrace {
+			// Each attempt has its own trace rather than composed with the previous ones
+			req.Request = req.WithContext(ctx)
+		}
+		req.attemptNum = attemptNum
+		start := time.Now()
+		var ar *Response
+		ar, err = attempt(req)
+		duration := time.Since(start)
+		resp.Response, resp.clientTrace = nil, nil
+		if ar != nil {
+			resp.Response, resp.clientTrace = ar.Response, ar.clientTrace
+		}
+		if resp.clientTrace != nil {
 			resp.clientTrace.done()
+			resp.traces = append(resp.traces, newAttemptTrace(resp.clientTrace, attemptNum, req, resp.Response, err))
+		}
+		if c.breaker != nil {
+			c.breaker.done(req, probe, resp, err)
+		}
+		if c.budget != nil {
+			if req.retrier != nil {
+				c.budget.record(req.retrier.failed(resp, err))
+			} else {
+				c.budget.record(attemptFailed(resp, err))
+			}
 		}
 
-		if req.retrier == nil || !req.retrier.on(req.Context(), attemptNum, resp, err) {
+		if req.retrier == nil {
 			return resp, err
 		}
 
+		attempts = append(attempts, newAttempt(resp, err, duration))
+		resp.attempts = attempts
+		if !req.retrier.on(req.Context(), attemptNum, resp, err) ||
+			!req.retrier.safe(req, err, wt.done()) ||
+			(c.breaker != nil && c.breaker.isOpen(req)) ||
+			(c.budget != nil && !c.budget.allow()) {
+			return resp, retryError(attempts, err)
+		}
+
 		sleep = req.retrier.backoff.Wait(attemptNum, resp, err)
 		// Drain Response.Body to enable TCP/TLS connection reuse
 		if err == nil && drainBody(resp.Body, ioutil.Discard) != http.ErrBodyReadAfterClose {
@@ -285,14 +440,55 @@ func (c *Client) doWithRetry(req *Request) (*Response, error) {
 			req.Body, _ = req.GetBody()
 		}
 
+		start = time.Now()
 		select {
 		case <-time.After(sleep):
+			attempts[attemptNum].Backoff = time.Since(start)
 		case <-req.Context().Done():
-			return resp, req.Context().Err()
+			attempts[attemptNum].Backoff = time.Since(start)
+			return resp, retryError(attempts, req.Context().Err())
 		}
 	}
 }
 
+// Send an attempt of req, it's hedged if enabled and applicable.
+func (c *Client) sendAttempt(req *Request) (*Response, error) {
+	resp := new(Response)
+	var err error
+	if req.hedger != nil && req.hedger.applicable(req) {
+		// Each hedged copy has its own trace, the winner's is kept
+		resp.Response, resp.clientTrace, err = c.doHedged(req)
+		return resp, err
+	}
+
+	if req.clientTrace {
+		resp.clientTrace = &clientTrace{start: time.Now()}
+		resp.clientTrace.modifyRequest(req)
+	}
+	resp.Response, err = c.doAttempt(req)
+	return resp, err
+}
+
+// Send an attempt of req, which is limited by the per-attempt timeout of req's retrier if specified.
+func (c *Client) doAttempt(req *Request) (*http.Response, error) {
+	if req.retrier == nil || req.retrier.perAttemptTimeout <= 0 {
+		return c.do(req.Request)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), req.retrier.perAttemptTimeout)
+	resp, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		if ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+			err = attemptTimeout(err)
+		}
+		return resp, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
 func (c *Client) do(req *http.Request) (*http.Response, error) {
 	resp, err := c.Client.Do(req)
 	if err != nil {
@@ -311,9 +507,3 @@ func (c *Client) do(req *http.Request) (*http.Response, error) {
 
 	return resp, nil
 }
-
-func (c *Client) onAfterResponse(resp *Response, err error) {
-	for _, callback := range c.afterResponseCallbacks {
-		callback.Exit(resp, err)
-	}
-}
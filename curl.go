@@ -0,0 +1,546 @@
+package ghttp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	shellSafeRegexp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+)
+
+type (
+	curlOptions struct {
+		client        *Client
+		redactHeaders map[string]bool
+	}
+
+	// CurlOption configures how to export a request as a curl command.
+	CurlOption func(co *curlOptions)
+)
+
+// WithCurlClient is a curl option that specifies the client to send the request,
+// so that the cookies from its jar, the TLS verification and proxy settings are exported too.
+func WithCurlClient(client *Client) CurlOption {
+	return func(co *curlOptions) {
+		co.client = client
+	}
+}
+
+// WithCurlRedactHeaders is a curl option that specifies the headers whose values are
+// replaced with RedactedValue, e.g. Authorization.
+// The cookies from the client jar are redacted too if Cookie is specified.
+func WithCurlRedactHeaders(keys ...string) CurlOption {
+	return func(co *curlOptions) {
+		for _, key := range keys {
+			co.redactHeaders[http.CanonicalHeaderKey(key)] = true
+		}
+	}
+}
+
+// ToCurl returns a curl command line equivalent to req, in which the arguments are shell-escaped.
+// The multipart payload set by Request.SetFiles is exported as -F options referencing the files
+// by their filenames, which must be available where the command runs.
+func (req *Request) ToCurl(opts ...CurlOption) (string, error) {
+	co := &curlOptions{redactHeaders: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(co)
+	}
+
+	// curl sends a POST with the data unless told otherwise
+	hasBody := req.formData != nil || !bodyEmpty(req.Body)
+	args := []string{"curl"}
+	switch {
+	case req.Method == MethodGet && !hasBody:
+	case req.Method == MethodHead && !hasBody:
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X", req.Method)
+	}
+	args = append(args, shellQuote(req.URL.String()))
+
+	var transport *http.Transport
+	if co.client != nil {
+		transport, _ = co.client.Transport.(*http.Transport)
+	}
+	compressed := strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") ||
+		(transport != nil && !transport.DisableCompression)
+
+	if req.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+req.Host))
+	}
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if compressed && k == "Accept-Encoding" ||
+			req.formData != nil && k == "Content-Type" {
+			continue
+		}
+		for _, v := range req.Header[k] {
+			if co.redactHeaders[k] {
+				v = RedactedValue
+			}
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	if co.client != nil && co.client.Jar != nil {
+		if cookies := co.client.Jar.Cookies(req.URL); len(cookies) > 0 {
+			pairs := make([]string, len(cookies))
+			for i, c := range cookies {
+				pairs[i] = c.Name + "="
+				if co.redactHeaders["Cookie"] {
+					pairs[i] += RedactedValue
+				} else {
+					pairs[i] += c.Value
+				}
+			}
+			args = append(args, "--cookie", shellQuote(strings.Join(pairs, "; ")))
+		}
+	}
+
+	if req.formData != nil {
+		args = append(args, curlFormArgs(req.formData)...)
+	} else if hasBody {
+		if err := req.bufferBody(); err != nil {
+			return "", err
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "--data-binary", shellQuote(string(b)))
+	}
+
+	if compressed {
+		args = append(args, "--compressed")
+	}
+	if transport != nil {
+		if transport.TLSClientConfig != nil && transport.TLSClientConfig.InsecureSkipVerify {
+			args = append(args, "-k")
+		}
+		if transport.Proxy != nil {
+			if proxy, err := transport.Proxy(req.Request); err == nil && proxy != nil {
+				args = append(args, "--proxy", shellQuote(proxy.String()))
+			}
+		}
+	}
+
+	return strings.Join(args, " "), nil
+}
+
+func curlFormArgs(fd *FormData) []string {
+	var args []string
+	keys := make([]string, 0, len(fd.files))
+	for k := range fd.files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f := fd.files[k]
+		v := k + "=@" + valueOrDefault(f.filename, "-")
+		if f.mime != "" {
+			v += ";type=" + f.mime
+		}
+		args = append(args, "-F", shellQuote(v))
+	}
+
+	form := fd.form.Decode()
+	keys = keys[:0]
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range form[k] {
+			args = append(args, "--form-string", shellQuote(k+"="+v))
+		}
+	}
+	return args
+}
+
+// Quote s for POSIX shells if necessary.
+func shellQuote(s string) string {
+	if shellSafeRegexp.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// ParseCurl parses a curl command line, e.g. from "Copy as cURL" of the browser devtools, into a Request.
+// It understands -X, -H, -d, --data-raw, --data-binary, --data-urlencode, -F, --form-string, -u, -b, -A, -e,
+// -G and -I, the output only options like -s, -v, -L and --compressed are ignored,
+// other options result in an error.
+func ParseCurl(cmd string) (*Request, error) {
+	args, err := shellSplit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("ghttp: not a curl command")
+	}
+
+	p := &curlParser{header: make(http.Header), files: make(Files), form: make(Form)}
+	if err = p.parse(args[1:]); err != nil {
+		return nil, err
+	}
+	return p.request()
+}
+
+type curlParser struct {
+	method    string
+	url       string
+	header    http.Header
+	data      []string
+	get       bool
+	head      bool
+	multipart bool
+	files     Files
+	form      Form
+	username  string
+	password  string
+	basicAuth bool
+}
+
+// Options without a value, which don't affect the request.
+var curlIgnoredOptions = map[string]bool{
+	"-s": true, "--silent": true,
+	"-S": true, "--show-error": true,
+	"-v": true, "--verbose": true,
+	"-i": true, "--include": true,
+	"-L": true, "--location": true,
+	"--compressed": true,
+}
+
+// Short options which take a value.
+var curlValueOptions = map[byte]bool{
+	'X': true, 'H': true, 'd': true, 'F': true, 'u': true, 'b': true, 'A': true, 'e': true,
+}
+
+func (p *curlParser) parse(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if p.url != "" {
+				return fmt.Errorf("ghttp: unexpected curl argument: %q", arg)
+			}
+			p.url = arg
+			continue
+		}
+
+		name, value, hasValue := arg, "", false
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			if curlValueOptions[arg[1]] {
+				// e.g. -XPOST
+				name, value, hasValue = arg[:2], arg[2:], true
+			} else {
+				// e.g. -sSL
+				for j := 1; j < len(arg); j++ {
+					if err := p.apply("-"+arg[j:j+1], ""); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+
+		if p.takesValue(name) && !hasValue {
+			i++
+			if i >= len(args) {
+				return fmt.Errorf("ghttp: curl option %s requires a value", name)
+			}
+			value = args[i]
+		}
+		if err := p.apply(name, value); err != nil {
+			return err
+		}
+	}
+
+	if p.url == "" {
+		return errors.New("ghttp: no URL specified in curl command")
+	}
+	return nil
+}
+
+func (p *curlParser) takesValue(name string) bool {
+	switch name {
+	case "--request", "--header", "--data", "--data-ascii", "--data-raw", "--data-binary", "--data-urlencode",
+		"--form", "--form-string", "--user", "--cookie", "--user-agent", "--referer", "--url":
+		return true
+	}
+	return len(name) == 2 && curlValueOptions[name[1]]
+}
+
+func (p *curlParser) apply(name string, value string) error {
+	switch name {
+	case "-X", "--request":
+		p.method = value
+	case "-H", "--header":
+		p.addHeader(value)
+	case "-d", "--data", "--data-ascii", "--data-binary":
+		if strings.HasPrefix(value, "@") {
+			return fmt.Errorf("ghttp: reading curl data from file isn't supported: %s %s", name, value)
+		}
+		p.data = append(p.data, value)
+	case "--data-raw":
+		p.data = append(p.data, value)
+	case "--data-urlencode":
+		data, err := curlURLEncode(value)
+		if err != nil {
+			return err
+		}
+		p.data = append(p.data, data)
+	case "-F", "--form":
+		return p.addFormPart(value)
+	case "--form-string":
+		k, v := splitPair(value, "=")
+		p.addFormField(k, v)
+		p.multipart = true
+	case "-u", "--user":
+		p.username, p.password = splitPair(value, ":")
+		p.basicAuth = true
+	case "-b", "--cookie":
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("ghttp: reading curl cookies from file isn't supported: %s %s", name, value)
+		}
+		p.header.Add("Cookie", value)
+	case "-A", "--user-agent":
+		p.header.Set("User-Agent", value)
+	case "-e", "--referer":
+		p.header.Set("Referer", value)
+	case "-G", "--get":
+		p.get = true
+	case "-I", "--head":
+		p.head = true
+	case "--url":
+		p.url = value
+	default:
+		if !curlIgnoredOptions[name] {
+			return fmt.Errorf("ghttp: unsupported curl option: %s", name)
+		}
+	}
+	return nil
+}
+
+func (p *curlParser) addHeader(value string) {
+	if strings.HasSuffix(value, ";") && !strings.Contains(value, ":") {
+		// "Name;" sends a header with empty value
+		p.header[http.CanonicalHeaderKey(strings.TrimSuffix(value, ";"))] = []string{""}
+		return
+	}
+
+	k, v := splitPair(value, ":")
+	k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+	v = strings.TrimSpace(v)
+	if v == "" {
+		// "Name:" removes the header
+		p.header.Del(k)
+		return
+	}
+	p.header.Add(k, v)
+}
+
+func (p *curlParser) addFormPart(value string) error {
+	p.multipart = true
+	k, v := splitPair(value, "=")
+	switch {
+	case strings.HasPrefix(v, "@"):
+		params := strings.Split(v[1:], ";")
+		file, err := Open(params[0])
+		if err != nil {
+			return err
+		}
+		for _, param := range params[1:] {
+			pk, pv := splitPair(param, "=")
+			switch pk {
+			case "type":
+				file.WithMIME(pv)
+			case "filename":
+				file.WithFilename(pv)
+			}
+		}
+		p.files[k] = file
+	case strings.HasPrefix(v, "<"):
+		return fmt.Errorf("ghttp: reading curl form field from file isn't supported: -F %s", value)
+	default:
+		p.addFormField(k, v)
+	}
+	return nil
+}
+
+func (p *curlParser) addFormField(k string, v string) {
+	vs, _ := p.form[k].([]string)
+	p.form[k] = append(vs, v)
+}
+
+func (p *curlParser) request() (*Request, error) {
+	method := p.method
+	if method == "" {
+		switch {
+		case p.head:
+			method = MethodHead
+		case p.get || len(p.data) == 0 && !p.multipart:
+			method = MethodGet
+		default:
+			method = MethodPost
+		}
+	}
+
+	req, err := NewRequest(method, p.url)
+	if err != nil {
+		return nil, err
+	}
+
+	data := strings.Join(p.data, "&")
+	switch {
+	case p.get && len(p.data) > 0:
+		if req.URL.RawQuery != "" {
+			req.URL.RawQuery += "&"
+		}
+		req.URL.RawQuery += data
+	case p.multipart:
+		fd := NewMultipart(p.files).WithForm(p.form)
+		req.SetBody(fd)
+		req.SetContentType(fd.ContentType())
+	case len(p.data) > 0:
+		req.SetBody(strings.NewReader(data))
+		req.SetContentType("application/x-www-form-urlencoded")
+	}
+
+	for k, vs := range p.header {
+		req.Header[k] = vs
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+	if p.basicAuth {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	return req, nil
+}
+
+// Encode the value of --data-urlencode, which is in the form of content, =content or name=content.
+func curlURLEncode(value string) (string, error) {
+	if i := strings.IndexAny(value, "=@"); i >= 0 && value[i] == '@' {
+		return "", fmt.Errorf("ghttp: reading curl data from file isn't supported: --data-urlencode %s", value)
+	}
+
+	i := strings.Index(value, "=")
+	switch {
+	case i < 0:
+		return neturl.QueryEscape(value), nil
+	case i == 0:
+		return neturl.QueryEscape(value[1:]), nil
+	default:
+		return value[:i] + "=" + neturl.QueryEscape(value[i+1:]), nil
+	}
+}
+
+func splitPair(s string, sep string) (string, string) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+len(sep):]
+}
+
+// Split a command line into arguments like a POSIX shell, which supports single quotes,
+// double quotes, ANSI-C quotes ($'...'), backslash escapes and line continuations.
+func shellSplit(s string) ([]string, error) {
+	var (
+		args    []string
+		sb      strings.Builder
+		inArg   bool
+		escapes = map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', '0': 0}
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		case c == '\\':
+			i++
+			if i < len(s) && s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			if i >= len(s) || s[i] == '\n' {
+				continue
+			}
+			sb.WriteByte(s[i])
+			inArg = true
+		case c == '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, errors.New("ghttp: unterminated single quote")
+			}
+			sb.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+			inArg = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			i += 2
+			for ; i < len(s) && s[i] != '\''; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					if e, ok := escapes[s[i]]; ok {
+						sb.WriteByte(e)
+						continue
+					}
+					if s[i] == 'x' && i+2 < len(s) {
+						if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+							sb.WriteByte(byte(b))
+							i += 2
+							continue
+						}
+					}
+					sb.WriteByte('\\')
+				}
+				sb.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("ghttp: unterminated ANSI-C quote")
+			}
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				sb.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("ghttp: unterminated double quote")
+			}
+			inArg = true
+		default:
+			sb.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,168 @@
+package ghttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_ToCurl(t *testing.T) {
+	req, _ := NewRequest(MethodGet, "https://httpbin.org/get?a=1&b=2")
+	cmd, err := req.ToCurl()
+	require.NoError(t, err)
+	assert.Equal(t, "curl 'https://httpbin.org/get?a=1&b=2'", cmd)
+
+	req, _ = NewRequest(MethodHead, "https://httpbin.org/get")
+	cmd, err = req.ToCurl()
+	require.NoError(t, err)
+	assert.Equal(t, "curl --head https://httpbin.org/get", cmd)
+
+	// Without -X, curl would send the data as a POST
+	req, _ = NewRequest(MethodGet, "https://httpbin.org/anything")
+	req.SetText("hello")
+	cmd, err = req.ToCurl()
+	require.NoError(t, err)
+	assert.Equal(t, "curl -X GET https://httpbin.org/anything "+
+		"-H 'Content-Type: text/plain; charset=utf-8' --data-binary hello", cmd)
+
+	req, _ = NewRequest(MethodPost, "https://httpbin.org/post")
+	req.SetBearerToken("secret")
+	req.SetText("it's me")
+	cmd, err = req.ToCurl(WithCurlRedactHeaders("authorization"))
+	require.NoError(t, err)
+	assert.Equal(t, "curl -X POST https://httpbin.org/post "+
+		"-H 'Authorization: REDACTED' "+
+		"-H 'Content-Type: text/plain; charset=utf-8' "+
+		`--data-binary 'it'\''s me'`, cmd)
+	// The body is still readable
+	cmd, err = req.ToCurl()
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(cmd, `--data-binary 'it'\''s me'`))
+
+	req, _ = NewRequest(MethodPost, "https://httpbin.org/post")
+	req.SetFiles(Files{
+		"file1": FileFromReader(strings.NewReader("hello")).WithFilename("a.txt").WithMIME("text/plain"),
+		"file2": FileFromReader(strings.NewReader("world")).WithFilename("b.txt"),
+	})
+	cmd, err = req.ToCurl()
+	require.NoError(t, err)
+	assert.Equal(t, "curl -X POST https://httpbin.org/post "+
+		"-F 'file1=@a.txt;type=text/plain' -F file2=@b.txt", cmd)
+
+	req, _ = NewRequest(MethodPost, "https://httpbin.org/post")
+	req.SetBody(NewMultipart(nil).WithForm(Form{"k": "v v"}))
+	cmd, err = req.ToCurl()
+	require.NoError(t, err)
+	assert.Equal(t, "curl -X POST https://httpbin.org/post --form-string 'k=v v'", cmd)
+}
+
+func TestRequest_ToCurlWithClient(t *testing.T) {
+	client := New()
+	client.DisableTLSVerify()
+	client.SetProxy(ProxyURL("http://127.0.0.1:7890"))
+	client.AddCookies("https://httpbin.org", &http.Cookie{Name: "session", Value: "abc"})
+
+	req, _ := NewRequest(MethodGet, "https://httpbin.org/cookies")
+	cmd, err := req.ToCurl(WithCurlClient(client))
+	require.NoError(t, err)
+	assert.Equal(t, "curl https://httpbin.org/cookies --cookie session=abc --compressed -k "+
+		"--proxy http://127.0.0.1:7890", cmd)
+
+	cmd, err = req.ToCurl(WithCurlClient(client), WithCurlRedactHeaders("Cookie"))
+	require.NoError(t, err)
+	assert.Contains(t, cmd, "--cookie session=REDACTED")
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "''", shellQuote(""))
+	assert.Equal(t, "abc", shellQuote("abc"))
+	assert.Equal(t, "'a b'", shellQuote("a b"))
+	assert.Equal(t, `'a'\''b'`, shellQuote("a'b"))
+	assert.Equal(t, "'$HOME'", shellQuote("$HOME"))
+}
+
+func TestParseCurl(t *testing.T) {
+	req, err := ParseCurl(`curl 'https://httpbin.org/post?a=1' \
+  -H 'accept: application/json' \
+  -H $'x-note: it\'s\tfine' \
+  -b 'session=abc; lang=en' \
+  -A "ghttp \"test\"" \
+  -e https://httpbin.org \
+  -u user:pass \
+  --data-raw '{"k":"v"}' \
+  --compressed -sSL`)
+	require.NoError(t, err)
+	assert.Equal(t, MethodPost, req.Method)
+	assert.Equal(t, "https://httpbin.org/post?a=1", req.URL.String())
+	assert.Equal(t, "application/json", req.Header.Get("Accept"))
+	assert.Equal(t, "it's\tfine", req.Header.Get("X-Note"))
+	assert.Equal(t, "session=abc; lang=en", req.Header.Get("Cookie"))
+	assert.Equal(t, `ghttp "test"`, req.Header.Get("User-Agent"))
+	assert.Equal(t, "https://httpbin.org", req.Header.Get("Referer"))
+	username, password, ok := req.BasicAuth()
+	if assert.True(t, ok) {
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+	}
+	body, err := req.GetBody()
+	require.NoError(t, err)
+	b, _ := ioutil.ReadAll(body)
+	assert.Equal(t, `{"k":"v"}`, string(b))
+
+	req, err = ParseCurl(`curl -G https://httpbin.org/get -d a=1 --data-urlencode 'b=x y' --data-urlencode '=&'`)
+	require.NoError(t, err)
+	assert.Equal(t, MethodGet, req.Method)
+	assert.Equal(t, "https://httpbin.org/get?a=1&b=x+y&%26", req.URL.String())
+
+	req, err = ParseCurl(`curl -XPUT https://httpbin.org/put -F 'k=v' --form-string 'at=@home' -F 'file=@testdata/testfile1.txt;type=text/plain'`)
+	require.NoError(t, err)
+	assert.Equal(t, MethodPut, req.Method)
+	if assert.NotNil(t, req.formData) {
+		assert.Equal(t, Form{"k": []string{"v"}, "at": []string{"@home"}}, req.formData.form)
+		if assert.Contains(t, req.formData.files, "file") {
+			assert.Equal(t, "testfile1.txt", req.formData.files["file"].filename)
+			assert.Equal(t, "text/plain", req.formData.files["file"].mime)
+		}
+	}
+	assert.True(t, strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data; boundary="))
+
+	req, err = ParseCurl(`curl -I https://httpbin.org/get`)
+	require.NoError(t, err)
+	assert.Equal(t, MethodHead, req.Method)
+
+	for _, cmd := range []string{
+		`wget https://httpbin.org/get`,
+		`curl -k https://httpbin.org/get`,
+		`curl -d @data.json https://httpbin.org/post`,
+		`curl -F 'k=<file.txt' https://httpbin.org/post`,
+		`curl -b cookies.txt https://httpbin.org/get`,
+		`curl -H`,
+		`curl -s`,
+		`curl 'https://httpbin.org/get`,
+	} {
+		_, err = ParseCurl(cmd)
+		assert.Error(t, err, cmd)
+	}
+}
+
+func TestParseCurl_RoundTrip(t *testing.T) {
+	req, _ := NewRequest(MethodPatch, "https://httpbin.org/patch?q=a+b")
+	req.Header.Set("X-Quote", `it's "quoted"`)
+	req.SetText("line1\nline2 'x'")
+	cmd, err := req.ToCurl()
+	require.NoError(t, err)
+
+	parsed, err := ParseCurl(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, req.Method, parsed.Method)
+	assert.Equal(t, req.URL.String(), parsed.URL.String())
+	assert.Equal(t, req.Header, parsed.Header)
+	body, err := parsed.GetBody()
+	require.NoError(t, err)
+	b, _ := ioutil.ReadAll(body)
+	assert.Equal(t, "line1\nline2 'x'", string(b))
+}
@@ -7,24 +7,117 @@ import (
 
 type (
 	debugger struct {
-		out  io.Writer
-		body bool
+		out      io.Writer
+		body     bool
+		curl     bool
+		curlOpts []CurlOption
+		redactor *redactor
+		format   dumpFormat
+		timing   bool
 	}
+
+	// DebugOption configures the debugger enabled by Client.EnableDebugging.
+	DebugOption func(d *debugger)
 )
 
-// Enter implements BeforeRequestCallback interface.
-func (d *debugger) Enter(req *Request) error {
-	err := dumpRequest(req, d.out, d.body)
+// WithDebugCurl is a debug option that logs each request as a curl command as well,
+// see Request.ToCurl for more details.
+func WithDebugCurl(opts ...CurlOption) DebugOption {
+	return func(d *debugger) {
+		d.curl = true
+		d.curlOpts = append(d.curlOpts, opts...)
+	}
+}
+
+// WithDebugRedaction is a debug option that masks the secrets in the output so that debugging
+// can be left on safely, see RedactOption for more details.
+func WithDebugRedaction(opts ...RedactOption) DebugOption {
+	return func(d *debugger) {
+		d.redactor = newRedactor(opts)
+	}
+}
+
+// WithDebugPretty is a debug option that pretty-prints the JSON, XML and form-encoded bodies,
+// and shows the binary bodies as a hex dump summary.
+func WithDebugPretty() DebugOption {
+	return func(d *debugger) {
+		d.format.pretty = true
+	}
+}
+
+// WithDebugColor is a debug option that specifies when to colorize the request and response lines.
+// By default is ColorNever.
+func WithDebugColor(mode ColorMode) DebugOption {
+	return func(d *debugger) {
+		d.format.color = mode.enabled(d.out)
+	}
+}
+
+// WithDebugTiming is a debug option that enables client trace for each request and
+// logs the timings after the response, see TraceInfo for more details.
+func WithDebugTiming() DebugOption {
+	return func(d *debugger) {
+		d.timing = true
+	}
+}
+
+func (d *debugger) wrap(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		if d.timing {
+			req.EnableClientTrace()
+		}
+		if err := d.enter(req); err != nil {
+			return nil, err
+		}
+
+		resp, err := next(req)
+		d.exit(resp, err)
+		return resp, err
+	}
+}
+
+func (d *debugger) enter(req *Request) error {
+	if d.redactor != nil {
+		redacted, err := d.redactor.redactRequest(req, d.body)
+		if err != nil {
+			fmt.Fprintf(d.out, "* ghttp [ERROR] %s\r\n", err.Error())
+			return err
+		}
+		req = redacted
+	}
+
+	if d.curl {
+		opts := d.curlOpts
+		if d.redactor != nil && d.redactor.headerMasked("Cookie") {
+			// The cookies from the jar aren't in the redacted headers
+			opts = append(opts[:len(opts):len(opts)], WithCurlRedactHeaders("Cookie"))
+		}
+		cmd, err := req.ToCurl(opts...)
+		if err != nil {
+			fmt.Fprintf(d.out, "* ghttp [ERROR] %s\r\n", err.Error())
+			return err
+		}
+		fmt.Fprintf(d.out, "* ghttp [CURL] %s\r\n", cmd)
+	}
+
+	err := dumpRequest(req, d.out, d.body, d.format)
 	if err != nil {
 		fmt.Fprintf(d.out, "* ghttp [ERROR] %s\r\n", err.Error())
 	}
 	return err
 }
 
-// Exit implements AfterResponseCallback interface.
-func (d *debugger) Exit(resp *Response, err error) {
+func (d *debugger) exit(resp *Response, err error) {
+	if err == nil && d.redactor != nil {
+		resp, err = d.redactor.redactResponse(resp, d.body)
+	}
 	if err == nil {
-		err = dumpResponse(resp, d.out, d.body)
+		err = dumpResponse(resp, d.out, d.body, d.format)
+	}
+	if err == nil && d.timing {
+		if info := resp.TraceInfo(); info != nil {
+			dumpTraceInfo(info, d.out)
+		}
 	}
 	if err != nil {
 		fmt.Fprintf(d.out, "* ghttp [ERROR] %s\r\n", err.Error())
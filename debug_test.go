@@ -4,14 +4,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	neturl "net/url"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestDebugger_Enter(t *testing.T) {
+func TestDebugger_Wrap(t *testing.T) {
 	dummyRequest := &Request{
 		Request: &http.Request{
 			Method: MethodPost,
@@ -29,7 +31,11 @@ func TestDebugger_Enter(t *testing.T) {
 		},
 	}
 	debugger := &debugger{out: ioutil.Discard, body: true}
-	err := debugger.Enter(dummyRequest)
+	handler := debugger.wrap(func(req *Request) (*Response, error) {
+		t.Fatal("next handler should not be called")
+		return nil, nil
+	})
+	_, err := handler(dummyRequest)
 	assert.Equal(t, errAccessDummyBody, err)
 }
 
@@ -37,6 +43,86 @@ func TestDebugger_Exit(t *testing.T) {
 	var sb strings.Builder
 	debugger := &debugger{out: &sb, body: true}
 	var dummyResponse *Response
-	debugger.Exit(dummyResponse, errAccessDummyBody)
+	debugger.exit(dummyResponse, errAccessDummyBody)
 	assert.Equal(t, fmt.Sprintf("* ghttp [ERROR] %s\r\n", errAccessDummyBody), sb.String())
 }
+
+func TestDebugger_Curl(t *testing.T) {
+	var sb strings.Builder
+	debugger := &debugger{out: &sb}
+	WithDebugCurl(WithCurlRedactHeaders("Authorization"))(debugger)
+
+	req, _ := NewRequest(MethodGet, "https://httpbin.org/get")
+	req.SetBearerToken("secret")
+	assert.NoError(t, debugger.enter(req))
+	assert.True(t, strings.HasPrefix(sb.String(),
+		"* ghttp [CURL] curl https://httpbin.org/get -H 'Authorization: REDACTED'\r\n> GET /get HTTP/1.1\r\n"))
+}
+
+func TestDebugger_Redaction(t *testing.T) {
+	var sb strings.Builder
+	debugger := &debugger{out: &sb, body: true}
+	WithDebugCurl()(debugger)
+	WithDebugRedaction(WithRedactJSONFields("password"), WithMaxBodySize(36))(debugger)
+
+	req, _ := NewRequest(MethodPost, "https://httpbin.org/post?token=abc")
+	req.SetBearerToken("secret")
+	_ = req.SetJSON(map[string]string{"username": "ghttp", "password": "secret"})
+	assert.NoError(t, debugger.enter(req))
+	assert.Equal(t, "* ghttp [CURL] curl -X POST 'https://httpbin.org/post?token=REDACTED' -H 'Authorization: REDACTED' "+
+		`-H 'Content-Type: application/json' --data-binary '{"password":"REDACTED","username":"g...(truncated)'`+"\r\n"+
+		"> POST /post?token=REDACTED HTTP/1.1\r\n"+
+		"> Host: httpbin.org\r\n"+
+		"> Authorization: REDACTED\r\n"+
+		"> Content-Type: application/json\r\n"+
+		">\r\n"+
+		`{"password":"REDACTED","username":"g...(truncated)`+"\r\n", sb.String())
+
+	// The original request is untouched
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+	assert.Equal(t, "token=abc", req.URL.RawQuery)
+	b, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, `{"password":"secret","username":"ghttp"}`, string(b))
+
+	sb.Reset()
+	resp := &Response{Response: &http.Response{
+		Proto:      "HTTP/1.1",
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Set-Cookie": {"session=abc"}},
+		Body:       ioutil.NopCloser(strings.NewReader("hello world")),
+	}}
+	debugger.exit(resp, nil)
+	assert.Equal(t, "< HTTP/1.1 200 OK\r\n< Set-Cookie: REDACTED\r\n<\r\nhello world\r\n", sb.String())
+	b, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "hello world", string(b))
+	assert.Equal(t, "session=abc", resp.Header.Get("Set-Cookie"))
+}
+
+func TestDebugger_Format(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}))
+	defer ts.Close()
+
+	var sb strings.Builder
+	client := New()
+	client.EnableDebugging(&sb, true, WithDebugPretty(), WithDebugColor(ColorAlways), WithDebugTiming())
+	resp, err := client.Post(ts.URL+"/post", WithForm(Form{"name": "ghttp"}))
+	require.NoError(t, err)
+	assert.NotNil(t, resp.TraceInfo())
+
+	out := sb.String()
+	assert.Contains(t, out, "\x1b[1;34m> POST /post HTTP/1.1\x1b[0m\r\n")
+	assert.Contains(t, out, ">\r\nname: ghttp\r\n")
+	assert.Contains(t, out, "\x1b[1;33m< HTTP/1.1 404 Not Found\x1b[0m\r\n")
+	assert.Contains(t, out, "<\r\n{\n  \"error\": \"not found\"\n}\r\n* ghttp [TIMING] dns_lookup=")
+	assert.True(t, strings.HasSuffix(out, "conn_reused=false\r\n"))
+
+	// The body is still readable after dumped
+	result, err := resp.Text()
+	require.NoError(t, err)
+	assert.Equal(t, `{"error":"not found"}`, result)
+}
@@ -5,9 +5,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
-
-	"github.com/winterssy/bufferpool"
 )
 
 var (
@@ -18,59 +17,77 @@ var (
 	}
 )
 
-func dumpRequestLine(req *Request, w io.Writer) {
-	fmt.Fprintf(w, "> %s %s %s\r\n", req.Method, req.URL.RequestURI(), req.Proto)
+func dumpRequestLine(req *Request, w io.Writer, f dumpFormat) {
+	var sb strings.Builder
+	writeRequestLine(req, &sb, req.URL.RequestURI(), "> ", "")
+	io.WriteString(w, f.paint(sb.String(), ansiBlue)+"\r\n")
+}
+
+func writeRequestLine(req *Request, w io.Writer, target string, prefix string, eol string) {
+	fmt.Fprintf(w, "%s%s %s %s%s", prefix, req.Method, target, req.Proto, eol)
 }
 
 func dumpRequestHeaders(req *Request, w io.Writer) {
+	writeRequestHeaders(req, w, "> ", "\r\n")
+}
+
+// Write the headers of req in a stable order, each line starts with prefix and ends with eol,
+// and an empty line is written at the end.
+func writeRequestHeaders(req *Request, w io.Writer, prefix string, eol string) {
 	host := req.Host
 	if req.Host == "" && req.URL != nil {
 		host = req.URL.Host
 	}
 	if host != "" {
-		fmt.Fprintf(w, "> Host: %s\r\n", host)
+		fmt.Fprintf(w, "%sHost: %s%s", prefix, host, eol)
 	}
 
 	if len(req.TransferEncoding) > 0 {
-		fmt.Fprintf(w, "> Transfer-Encoding: %s\r\n", strings.Join(req.TransferEncoding, ","))
+		fmt.Fprintf(w, "%sTransfer-Encoding: %s%s", prefix, strings.Join(req.TransferEncoding, ","), eol)
 	}
 	if req.Close {
-		io.WriteString(w, "> Connection: close\r\n")
+		fmt.Fprintf(w, "%sConnection: close%s", prefix, eol)
 	}
 
-	for k, vs := range req.Header {
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
 		if !reqWriteExcludeHeaderDump[k] {
-			for _, v := range vs {
-				fmt.Fprintf(w, "> %s: %s\r\n", k, v)
-			}
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			fmt.Fprintf(w, "%s%s: %s%s", prefix, k, v, eol)
 		}
 	}
-	io.WriteString(w, ">\r\n")
+	io.WriteString(w, strings.TrimSpace(prefix)+eol)
 }
 
-func dumpRequestBody(req *Request, w io.Writer) (err error) {
-	buf := bufferpool.Get()
-	defer buf.Free()
-	err = drainBody(req.Body, buf)
-	if err == nil {
-		req.SetContent(buf.Bytes())
-		_, _ = io.Copy(w, buf)
-		io.WriteString(w, "\r\n")
+func dumpRequestBody(req *Request, w io.Writer, f dumpFormat) error {
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
 	}
-	return
+
+	req.SetContent(b)
+	f.writeBody(w, req.Header.Get("Content-Type"), b)
+	return nil
 }
 
-func dumpRequest(req *Request, w io.Writer, body bool) (err error) {
-	dumpRequestLine(req, w)
+func dumpRequest(req *Request, w io.Writer, body bool, f dumpFormat) (err error) {
+	dumpRequestLine(req, w, f)
 	dumpRequestHeaders(req, w)
 	if body && !bodyEmpty(req.Body) {
-		err = dumpRequestBody(req, w)
+		err = dumpRequestBody(req, w, f)
 	}
 	return
 }
 
-func dumpResponseLine(resp *Response, w io.Writer) {
-	fmt.Fprintf(w, "< %s %s\r\n", resp.Proto, resp.Status)
+func dumpResponseLine(resp *Response, w io.Writer, f dumpFormat) {
+	line := fmt.Sprintf("< %s %s", resp.Proto, resp.Status)
+	io.WriteString(w, f.paint(line, f.statusColor(resp.StatusCode))+"\r\n")
 }
 
 func dumpResponseHeaders(resp *Response, w io.Writer) {
@@ -82,23 +99,31 @@ func dumpResponseHeaders(resp *Response, w io.Writer) {
 	io.WriteString(w, "<\r\n")
 }
 
-func dumpResponseBody(resp *Response, w io.Writer) (err error) {
-	buf := bufferpool.Get()
-	defer buf.Free()
-	err = drainBody(resp.Body, buf)
-	if err == nil {
-		resp.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
-		_, _ = io.Copy(w, buf)
-		io.WriteString(w, "\r\n")
+func dumpResponseBody(resp *Response, w io.Writer, f dumpFormat) error {
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
 	}
-	return
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	f.writeBody(w, resp.Header.Get("Content-Type"), b)
+	return nil
 }
 
-func dumpResponse(resp *Response, w io.Writer, body bool) (err error) {
-	dumpResponseLine(resp, w)
+func dumpResponse(resp *Response, w io.Writer, body bool, f dumpFormat) (err error) {
+	dumpResponseLine(resp, w, f)
 	dumpResponseHeaders(resp, w)
 	if body && !bodyEmpty(resp.Body) {
-		err = dumpResponseBody(resp, w)
+		err = dumpResponseBody(resp, w, f)
 	}
 	return
 }
+
+// Write the timings of info in a line.
+func dumpTraceInfo(info *TraceInfo, w io.Writer) {
+	fmt.Fprintf(w, "* ghttp [TIMING] dns_lookup=%s tcp_conn=%s tls_handshake=%s conn=%s server=%s response=%s "+
+		"total=%s conn_reused=%t\r\n",
+		info.DNSLookupTime, info.TCPConnTime, info.TLSHandshakeTime, info.ConnTime, info.ServerTime,
+		info.ResponseTime, info.TotalTime, info.ConnReused)
+}
@@ -259,6 +259,32 @@ func ExampleClient_RegisterBeforeRequestCallbacks() {
 	// 200
 }
 
+func ExampleClient_Use() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	withStatusLogging := func(next ghttp.Handler) ghttp.Handler {
+		return func(req *ghttp.Request) (*ghttp.Response, error) {
+			resp, err := next(req)
+			if err == nil {
+				fmt.Println(req.Method, resp.StatusCode)
+			}
+			return resp, err
+		}
+	}
+
+	client := ghttp.New()
+	client.Use(withStatusLogging)
+
+	_, _ = client.Get(ts.URL)
+	_, _ = client.Delete(ts.URL)
+	// Output:
+	// GET 204
+	// DELETE 204
+}
+
 func ExampleClient_EnableRateLimiting() {
 	client := ghttp.New()
 	client.EnableRateLimiting(rate.NewLimiter(1, 10))
@@ -289,6 +315,31 @@ func ExampleClient_SetMaxConcurrency() {
 	wg.Wait()
 }
 
+func ExampleClient_EnableCaching() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello world"))
+	}))
+	defer ts.Close()
+
+	client := ghttp.New()
+	client.EnableCaching(ghttp.NewMemoryCache(128))
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			log.Print(err)
+			return
+		}
+
+		data, _ := resp.Text()
+		fmt.Println(data, resp.CacheStatus())
+	}
+	// Output:
+	// hello world MISS
+	// hello world HIT
+}
+
 func ExampleClient_EnableDebugging() {
 	client := ghttp.New()
 	client.EnableDebugging(os.Stdout, true)
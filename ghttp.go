@@ -9,6 +9,9 @@ import (
 	"github.com/winterssy/gjson"
 )
 
+// Version is the version of ghttp.
+const Version = "0.1.0"
+
 type (
 	// KV maps a string key to an interface{} type value,
 	// It's typically used for request query parameters, form data or headers.
@@ -38,6 +41,14 @@ type (
 		// Exit is called when a request ends.
 		Exit(resp *Response, err error)
 	}
+
+	// Handler is a function that sends a request and returns its response.
+	Handler func(req *Request) (*Response, error)
+
+	// Middleware is a function that wraps a Handler to provide extra behaviors.
+	// A middleware can manipulate the request before calling next, replace the response
+	// after calling next, or even short-circuit the call with a synthetic response.
+	Middleware func(next Handler) Handler
 )
 
 // Decode translates kv and returns the equivalent request query parameters, form data or headers.
@@ -0,0 +1,391 @@
+package ghttp
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/winterssy/gjson"
+)
+
+const (
+	harVersion     = "1.2"
+	harCreatorName = "ghttp"
+)
+
+type (
+	// HAR is an HTTP Archive 1.2 document.
+	// See: http://www.softwareishard.com/blog/har-12-spec/
+	HAR struct {
+		Log *HARLog `json:"log"`
+	}
+
+	// HARLog is the root of a HAR document.
+	HARLog struct {
+		Version string      `json:"version"`
+		Creator *HARCreator `json:"creator"`
+		Entries []*HAREntry `json:"entries"`
+	}
+
+	// HARCreator is the application created a HAR document.
+	HARCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	// HAREntry is an exported HTTP request.
+	HAREntry struct {
+		StartedDateTime string       `json:"startedDateTime"`
+		Time            float64      `json:"time"`
+		Request         *HARRequest  `json:"request"`
+		Response        *HARResponse `json:"response"`
+		Cache           struct{}     `json:"cache"`
+		Timings         *HARTimings  `json:"timings"`
+
+		// Error is a custom field records the error of a failed request.
+		Error string `json:"_error,omitempty"`
+	}
+
+	// HARRequest is the detailed info about a request.
+	HARRequest struct {
+		Method      string          `json:"method"`
+		URL         string          `json:"url"`
+		HTTPVersion string          `json:"httpVersion"`
+		Cookies     []*HARCookie    `json:"cookies"`
+		Headers     []*HARNameValue `json:"headers"`
+		QueryString []*HARNameValue `json:"queryString"`
+		PostData    *HARPostData    `json:"postData,omitempty"`
+		HeadersSize int64           `json:"headersSize"`
+		BodySize    int64           `json:"bodySize"`
+	}
+
+	// HARResponse is the detailed info about a response.
+	HARResponse struct {
+		Status      int             `json:"status"`
+		StatusText  string          `json:"statusText"`
+		HTTPVersion string          `json:"httpVersion"`
+		Cookies     []*HARCookie    `json:"cookies"`
+		Headers     []*HARNameValue `json:"headers"`
+		Content     *HARContent     `json:"content"`
+		RedirectURL string          `json:"redirectURL"`
+		HeadersSize int64           `json:"headersSize"`
+		BodySize    int64           `json:"bodySize"`
+	}
+
+	// HARCookie is a cookie of a request or response.
+	HARCookie struct {
+		Name     string `json:"name"`
+		Value    string `json:"value"`
+		Path     string `json:"path,omitempty"`
+		Domain   string `json:"domain,omitempty"`
+		Expires  string `json:"expires,omitempty"`
+		HTTPOnly bool   `json:"httpOnly,omitempty"`
+		Secure   bool   `json:"secure,omitempty"`
+	}
+
+	// HARNameValue is a header or query param.
+	HARNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	// HARPostData is the posted data of a request.
+	HARPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Comment  string `json:"comment,omitempty"`
+	}
+
+	// HARContent is the content of a response.
+	HARContent struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+		Comment  string `json:"comment,omitempty"`
+	}
+
+	// HARTimings are the durations of the phases of a request in milliseconds,
+	// -1 means the timing doesn't apply to the request.
+	HARTimings struct {
+		Blocked float64 `json:"blocked"`
+		DNS     float64 `json:"dns"`
+		Connect float64 `json:"connect"`
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+		SSL     float64 `json:"ssl"`
+	}
+
+	// HARRecorder captures the requests of a client in memory, which can be exported
+	// as a HAR document and opened in the browser devtools.
+	// Install it with Client.EnableHARRecording.
+	HARRecorder struct {
+		body        bool
+		maxBodySize int64
+
+		mu      sync.Mutex
+		entries []*HAREntry
+	}
+
+	// HAROption configures a HAR recorder.
+	HAROption func(hr *HARRecorder)
+)
+
+// NewHARRecorder returns a HAR recorder. By default the bodies aren't captured.
+func NewHARRecorder(opts ...HAROption) *HARRecorder {
+	hr := &HARRecorder{}
+	for _, opt := range opts {
+		opt(hr)
+	}
+	return hr
+}
+
+// WithHARBody is a HAR option that captures the request and response bodies,
+// up to maxSize bytes each, while maxSize less than or equal to zero means no limit.
+func WithHARBody(maxSize int64) HAROption {
+	return func(hr *HARRecorder) {
+		hr.body = true
+		hr.maxBodySize = maxSize
+	}
+}
+
+// HAR returns the HAR document of the captured requests.
+func (hr *HARRecorder) HAR() *HAR {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	// Copy the entries since their timings are updated once the response bodies are read
+	entries := make([]*HAREntry, len(hr.entries))
+	for i, entry := range hr.entries {
+		e := *entry
+		timings := *entry.Timings
+		e.Timings = &timings
+		entries[i] = &e
+	}
+	return &HAR{
+		Log: &HARLog{
+			Version: harVersion,
+			Creator: &HARCreator{Name: harCreatorName, Version: Version},
+			Entries: entries,
+		},
+	}
+}
+
+// WriteTo writes the HAR document of the captured requests to w, implements io.WriterTo interface.
+func (hr *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	b, err := encodeJSON(hr.HAR(), func(enc *gjson.Encoder) {
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// Reset discards the captured requests.
+func (hr *HARRecorder) Reset() {
+	hr.mu.Lock()
+	hr.entries = nil
+	hr.mu.Unlock()
+}
+
+func (hr *HARRecorder) wrap(jar http.CookieJar) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			req.EnableClientTrace()
+			entry := &HAREntry{
+				StartedDateTime: time.Now().Format(time.RFC3339Nano),
+			}
+			var err error
+			if entry.Request, err = hr.request(req, jar); err != nil {
+				return nil, err
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			entry.Response = hr.response(resp)
+			entry.Timings, entry.Time = harTimings(resp)
+
+			hr.mu.Lock()
+			hr.entries = append(hr.entries, entry)
+			hr.mu.Unlock()
+			if err == nil && !bodyEmpty(resp.Body) {
+				// Finish the timings once the body is transferred
+				resp.Body = &doneBody{
+					ReadCloser: resp.Body,
+					done: func() {
+						resp.bodyRead()
+						timings, total := harTimings(resp)
+						hr.mu.Lock()
+						entry.Timings, entry.Time = timings, total
+						hr.mu.Unlock()
+					},
+				}
+			}
+			return resp, err
+		}
+	}
+}
+
+func (hr *HARRecorder) request(req *Request, jar http.CookieJar) (*HARRequest, error) {
+	r := &HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: []*HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	if jar != nil {
+		r.Cookies = append(r.Cookies, harCookies(jar.Cookies(req.URL))...)
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			r.QueryString = append(r.QueryString, &HARNameValue{Name: k, Value: v})
+		}
+	}
+
+	if !hr.body || bodyEmpty(req.Body) {
+		return r, nil
+	}
+
+	b, err := req.peekBody()
+	if err != nil {
+		return nil, err
+	}
+
+	r.BodySize = int64(len(b))
+	r.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type")}
+	b, truncated := truncateBody(b, hr.maxBodySize)
+	r.PostData.Text = string(b)
+	if truncated {
+		r.PostData.Comment = "truncated"
+	}
+	return r, nil
+}
+
+func (hr *HARRecorder) response(resp *Response) *HARResponse {
+	r := &HARResponse{
+		Cookies:     []*HARCookie{},
+		Headers:     []*HARNameValue{},
+		Content:     &HARContent{Size: -1},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if resp == nil || resp.Response == nil {
+		return r
+	}
+
+	r.Status = resp.StatusCode
+	r.StatusText = http.StatusText(resp.StatusCode)
+	r.HTTPVersion = resp.Proto
+	r.Cookies = harCookies(resp.Cookies())
+	r.Headers = harHeaders(resp.Header)
+	r.RedirectURL = resp.Header.Get("Location")
+	r.BodySize = resp.ContentLength
+	r.Content.Size = resp.ContentLength
+	r.Content.MimeType = resp.Header.Get("Content-Type")
+	if !hr.body || bodyEmpty(resp.Body) {
+		return r
+	}
+
+	// Only read the captured part, the rest is left to the caller
+	b, err := resp.peekBody(hr.maxBodySize)
+	if err != nil {
+		r.Content.Comment = err.Error()
+		return r
+	}
+
+	b, truncated := truncateBody(b, hr.maxBodySize)
+	if !truncated && r.Content.Size < 0 {
+		r.Content.Size = int64(len(b))
+	}
+	if utf8.Valid(b) {
+		r.Content.Text = string(b)
+	} else {
+		r.Content.Text = base64.StdEncoding.EncodeToString(b)
+		r.Content.Encoding = "base64"
+	}
+	if truncated {
+		r.Content.Comment = "truncated"
+	}
+	return r
+}
+
+func harCookies(cookies []*http.Cookie) []*HARCookie {
+	hc := make([]*HARCookie, 0, len(cookies))
+	for _, c := range cookies {
+		cookie := &HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			cookie.Expires = c.Expires.Format(time.RFC3339)
+		}
+		hc = append(hc, cookie)
+	}
+	return hc
+}
+
+func harHeaders(header http.Header) []*HARNameValue {
+	hh := make([]*HARNameValue, 0, len(header))
+	for k, vs := range header {
+		for _, v := range vs {
+			hh = append(hh, &HARNameValue{Name: k, Value: v})
+		}
+	}
+	return hh
+}
+
+// Convert the trace info of resp to HAR timings, and the total time of the request.
+func harTimings(resp *Response) (*HARTimings, float64) {
+	timings := &HARTimings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: 0, Receive: 0, SSL: -1}
+	if resp == nil {
+		return timings, 0
+	}
+	ti := resp.TraceInfo()
+	if ti == nil {
+		return timings, 0
+	}
+
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	if !ti.ConnReused {
+		if ti.DNSLookupTime > 0 {
+			timings.DNS = ms(ti.DNSLookupTime)
+		}
+		if ti.TCPConnTime > 0 {
+			// Connect includes SSL as the spec says
+			timings.Connect = ms(ti.TCPConnTime + ti.TLSHandshakeTime)
+		}
+		if ti.TLSHandshakeTime > 0 {
+			timings.SSL = ms(ti.TLSHandshakeTime)
+		}
+	}
+	if blocked := ti.ConnTime - ti.DNSLookupTime - ti.TCPConnTime - ti.TLSHandshakeTime; blocked > 0 {
+		timings.Blocked = ms(blocked)
+	}
+	if ti.ServerTime > 0 {
+		timings.Wait = ms(ti.ServerTime)
+	}
+	// The body transfer is included once the body is read
+	if receive := ti.ResponseTime + ti.ResponseBodyTime; receive > 0 {
+		timings.Receive = ms(receive)
+	}
+	return timings, ms(ti.TotalTime + ti.ResponseBodyTime)
+}
@@ -0,0 +1,129 @@
+package ghttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_EnableHARRecording(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(bytes.Repeat(body, 2))
+	}))
+	defer ts.Close()
+
+	recorder := NewHARRecorder(WithHARBody(8))
+	client := New()
+	client.EnableHARRecording(recorder)
+
+	resp, err := client.Post(ts.URL+"/post",
+		WithQuery(Params{"k": "v"}),
+		WithCookies(Cookies{"n": "v"}),
+		WithText("hello world"),
+	)
+	require.NoError(t, err)
+	// The body is still readable after captured
+	result, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello worldhello world", result)
+	}
+
+	har := recorder.HAR()
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Equal(t, &HARCreator{Name: "ghttp", Version: Version}, har.Log.Creator)
+	require.Len(t, har.Log.Entries, 1)
+	entry := har.Log.Entries[0]
+	assert.NotEmpty(t, entry.StartedDateTime)
+	assert.True(t, entry.Time > 0)
+
+	assert.Equal(t, MethodPost, entry.Request.Method)
+	assert.Equal(t, []*HARNameValue{{Name: "k", Value: "v"}}, entry.Request.QueryString)
+	assert.Equal(t, []*HARCookie{{Name: "n", Value: "v"}}, entry.Request.Cookies)
+	if assert.NotNil(t, entry.Request.PostData) {
+		assert.Equal(t, "hello wo", entry.Request.PostData.Text)
+		assert.Equal(t, "truncated", entry.Request.PostData.Comment)
+	}
+	assert.Equal(t, int64(11), entry.Request.BodySize)
+
+	assert.Equal(t, http.StatusOK, entry.Response.Status)
+	assert.Equal(t, "OK", entry.Response.StatusText)
+	if assert.Len(t, entry.Response.Cookies, 1) {
+		assert.Equal(t, "session", entry.Response.Cookies[0].Name)
+		assert.True(t, entry.Response.Cookies[0].HTTPOnly)
+	}
+	assert.Equal(t, "text/plain; charset=utf-8", entry.Response.Content.MimeType)
+	assert.Equal(t, "hello wo", entry.Response.Content.Text)
+	assert.Equal(t, int64(22), entry.Response.Content.Size)
+	assert.True(t, entry.Timings.Wait >= 0)
+
+	var buf bytes.Buffer
+	_, err = recorder.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"version": "1.2"`)
+	assert.Contains(t, buf.String(), `"startedDateTime"`)
+
+	recorder.Reset()
+	assert.Empty(t, recorder.HAR().Log.Entries)
+
+	ts.Close()
+	_, err = client.Get(ts.URL)
+	assert.Error(t, err)
+	if entries := recorder.HAR().Log.Entries; assert.Len(t, entries, 1) {
+		assert.NotEmpty(t, entries[0].Error)
+		assert.Zero(t, entries[0].Response.Status)
+	}
+}
+
+func TestHARRecorder_NoBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello world"))
+	}))
+	defer ts.Close()
+
+	recorder := NewHARRecorder()
+	client := New()
+	client.EnableHARRecording(recorder)
+	_, err := client.Post(ts.URL, WithText("hello world"))
+	require.NoError(t, err)
+
+	entry := recorder.HAR().Log.Entries[0]
+	assert.Nil(t, entry.Request.PostData)
+	assert.Empty(t, entry.Response.Content.Text)
+}
+
+func TestHARRecorder_BodyTimings(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		time.Sleep(delay)
+		w.Write([]byte(" world"))
+	}))
+	defer ts.Close()
+
+	recorder := NewHARRecorder()
+	client := New()
+	client.EnableHARRecording(recorder)
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+
+	entry := recorder.HAR().Log.Entries[0]
+	assert.True(t, entry.Time < float64(delay/time.Millisecond))
+
+	// The body transfer is included once the body is read
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+	entry = recorder.HAR().Log.Entries[0]
+	assert.True(t, entry.Time >= float64(delay/time.Millisecond))
+	assert.True(t, entry.Timings.Receive >= float64(delay/time.Millisecond))
+}
@@ -0,0 +1,162 @@
+package ghttp
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+type (
+	hedger struct {
+		delay     time.Duration
+		maxHedges int
+	}
+
+	hedgeResult struct {
+		index  int
+		resp   *http.Response
+		ct     *clientTrace
+		err    error
+		cancel context.CancelFunc
+	}
+)
+
+// Report whether req can be hedged, that is, it's idempotent and its body can be replayed.
+func (h *hedger) applicable(req *Request) bool {
+	if h.maxHedges <= 0 {
+		return false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if req.Header.Get(IdempotencyKeyHeader) != "" {
+		return true
+	}
+	for _, method := range defaultRetryMethods {
+		if req.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (hr *hedgeResult) succeeded() bool {
+	return hr.err == nil && hr.resp.StatusCode < http.StatusInternalServerError
+}
+
+// Cancel hr and release its response, if any.
+func (hr *hedgeResult) discard() {
+	if hr.resp != nil {
+		io.Copy(ioutil.Discard, hr.resp.Body)
+		hr.resp.Body.Close()
+	}
+	hr.cancel()
+}
+
+// Send req and its hedged copies, return the first successful response.
+// If all copies fail, the last failure is returned.
+func (c *Client) doHedged(req *Request) (*http.Response, *clientTrace, error) {
+	h := req.hedger
+	results := make(chan *hedgeResult, h.maxHedges+1)
+	var cancels []context.CancelFunc
+	inflight, hedges := 0, 0
+
+	launch := func(primary bool) bool {
+		if !primary {
+			if hedges >= h.maxHedges || req.Context().Err() != nil {
+				return false
+			}
+			if c.concurrency != nil && !c.concurrency.tryAcquire() {
+				return false
+			}
+		}
+
+		ctx, cancel := context.WithCancel(req.Context())
+		r := &Request{Request: req.WithContext(ctx), retrier: req.retrier}
+		if !primary && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				if c.concurrency != nil {
+					c.concurrency.release()
+				}
+				return false
+			}
+			r.Body = body
+		}
+
+		var ct *clientTrace
+		if req.clientTrace {
+			ct = &clientTrace{start: time.Now()}
+			ct.modifyRequest(r)
+		}
+
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		inflight++
+		if !primary {
+			hedges++
+		}
+		go func() {
+			resp, err := c.doAttempt(r)
+			if !primary && c.concurrency != nil {
+				c.concurrency.release()
+			}
+			results <- &hedgeResult{index: index, resp: resp, ct: ct, err: err, cancel: cancel}
+		}()
+		return true
+	}
+
+	launch(true)
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+
+	var last *hedgeResult
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			if res.succeeded() {
+				if last != nil {
+					last.discard()
+				}
+				// Cancel the other copies only, the winner's context is cancelled
+				// once its body is closed
+				for i, cancel := range cancels {
+					if i != res.index {
+						cancel()
+					}
+				}
+				go func(n int) {
+					for ; n > 0; n-- {
+						(<-results).discard()
+					}
+				}(inflight)
+				res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: res.cancel}
+				return res.resp, res.ct, nil
+			}
+
+			if last != nil {
+				last.discard()
+			}
+			last = res
+			// Don't wait for the delay since a copy has failed
+			if !launch(false) && inflight == 0 {
+				if last.err != nil {
+					last.cancel()
+					return last.resp, last.ct, last.err
+				}
+				last.resp.Body = &cancelBody{ReadCloser: last.resp.Body, cancel: last.cancel}
+				return last.resp, last.ct, nil
+			}
+		case <-timer.C:
+			if launch(false) {
+				timer.Reset(h.delay)
+			}
+		}
+	}
+}
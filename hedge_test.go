@@ -0,0 +1,176 @@
+package ghttp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedger_Applicable(t *testing.T) {
+	h := &hedger{delay: time.Millisecond, maxHedges: 1}
+
+	req, _ := NewRequest(MethodGet, "https://httpbin.org/get")
+	assert.True(t, h.applicable(req))
+
+	req, _ = NewRequest(MethodPost, "https://httpbin.org/post")
+	assert.False(t, h.applicable(req))
+
+	req.Header.Set(IdempotencyKeyHeader, "key")
+	assert.True(t, h.applicable(req))
+
+	h.maxHedges = 0
+	assert.False(t, h.applicable(req))
+}
+
+func TestClient_Hedging(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch atomic.AddUint64(&counter, 1) {
+		case 1:
+			// The first copy stalls until it's cancelled
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetMaxConcurrency(3)
+
+	start := time.Now()
+	resp, err := client.Put(ts.URL,
+		WithText("hello world"),
+		WithHedging(50*time.Millisecond, 2),
+	)
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	result, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello world", result)
+	}
+	// The failed copy triggers the next one immediately
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&counter))
+	assert.Len(t, client.concurrency.ch, 0)
+
+	atomic.StoreUint64(&counter, 2)
+	_, err = client.Post(ts.URL,
+		WithText("hello world"),
+		WithHedging(time.Millisecond, 2),
+	)
+	require.NoError(t, err)
+	// POST isn't hedged
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&counter))
+}
+
+func TestClient_HedgingLargeBody(t *testing.T) {
+	const size = 8 << 20
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint64(&counter, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		chunk := bytes.Repeat([]byte("a"), 64<<10)
+		for n := 0; n < size; n += len(chunk) {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp, err := client.Get(ts.URL, WithHedging(50*time.Millisecond, 1))
+	require.NoError(t, err)
+	// The winner's body is read after the other copy is cancelled
+	b, err := resp.Content()
+	require.NoError(t, err)
+	assert.Len(t, b, size)
+}
+
+type (
+	hedgeTransport struct {
+		closed int32
+	}
+
+	hedgeBody struct {
+		io.ReadCloser
+		closed *int32
+	}
+)
+
+func (ht *hedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := DefaultTransport().RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusServiceUnavailable {
+		resp.Body = &hedgeBody{ReadCloser: resp.Body, closed: &ht.closed}
+	}
+	return resp, err
+}
+
+func (hb *hedgeBody) Close() error {
+	atomic.AddInt32(hb.closed, 1)
+	return hb.ReadCloser.Close()
+}
+
+func TestClient_HedgingReleaseFailed(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint64(&counter, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("unavailable"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	transport := &hedgeTransport{}
+	client := New()
+	client.Transport = transport
+	resp, err := client.Get(ts.URL, WithHedging(time.Second, 1))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// The failed copy is released once the hedged copy wins
+	assert.Equal(t, int32(1), atomic.LoadInt32(&transport.closed))
+	result, err := resp.Text()
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result)
+}
+
+func TestClient_HedgingAllFailed(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&counter, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.SetMaxConcurrency(2)
+	resp, err := client.Get(ts.URL,
+		WithHedging(time.Millisecond, 2),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	// Only one slot is left for the hedged copies
+	assert.True(t, atomic.LoadUint64(&counter) >= 2)
+	assert.True(t, atomic.LoadUint64(&counter) <= 3)
+}
@@ -0,0 +1,205 @@
+package ghttp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+const (
+	httpFileSeparator = "###"
+)
+
+var (
+	httpFileVariableRegexp = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+)
+
+// ParseHTTPFile parses the requests in the format of the .http files used by JetBrains IDEs and
+// VS Code REST Client, that is, raw HTTP requests separated by lines start with ###.
+// Each request consists of a request line, headers, an empty line and an optional body.
+// The lines start with # or // before the request line are comments.
+// The {{variable}} placeholders are replaced with the values of vars, it's an error if any
+// variable is undefined.
+func ParseHTTPFile(r io.Reader, vars KV) ([]*Request, error) {
+	values := vars.Decode()
+	substitute := func(lineNum int, s string) (string, error) {
+		var err error
+		s = httpFileVariableRegexp.ReplaceAllStringFunc(s, func(m string) string {
+			name := httpFileVariableRegexp.FindStringSubmatch(m)[1]
+			vs, ok := values[name]
+			if !ok || len(vs) == 0 {
+				if err == nil {
+					err = fmt.Errorf("ghttp: line %d: undefined variable %q", lineNum, name)
+				}
+				return m
+			}
+			return vs[0]
+		})
+		return s, err
+	}
+
+	var (
+		reqs    []*Request
+		block   []string
+		start   int
+		lineNum int
+	)
+	flush := func() error {
+		req, err := parseHTTPFileBlock(block, start, substitute)
+		if err == nil && req != nil {
+			reqs = append(reqs, req)
+		}
+		block = block[:0]
+		start = lineNum + 1
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	start = 1
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, httpFileSeparator) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// Parse the lines of a request, the first one is at line start.
+// It returns a nil request if the block only contains comments and empty lines.
+func parseHTTPFileBlock(lines []string, start int, substitute func(lineNum int, s string) (string, error)) (*Request, error) {
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") {
+			break
+		}
+	}
+	if i == len(lines) {
+		return nil, nil
+	}
+
+	requestLine, err := substitute(start+i, strings.TrimSpace(lines[i]))
+	if err != nil {
+		return nil, err
+	}
+	method, url := MethodGet, requestLine
+	if fields := strings.Fields(requestLine); len(fields) > 1 {
+		method, url = fields[0], fields[1]
+	}
+	// The query may be continued on the indented lines start with ? or &
+	for i++; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if lines[i] == line || !strings.HasPrefix(line, "?") && !strings.HasPrefix(line, "&") {
+			break
+		}
+		if line, err = substitute(start+i, line); err != nil {
+			return nil, err
+		}
+		url += line
+	}
+
+	headers := make([][2]string, 0)
+	host := ""
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			i++
+			break
+		}
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if line, err = substitute(start+i, line); err != nil {
+			return nil, err
+		}
+		k, v := splitPair(line, ":")
+		if v == "" && !strings.Contains(line, ":") {
+			return nil, fmt.Errorf("ghttp: line %d: invalid header %q", start+i, line)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if strings.EqualFold(k, "Host") {
+			host = v
+			continue
+		}
+		headers = append(headers, [2]string{k, v})
+	}
+
+	switch {
+	case strings.HasPrefix(url, "/"):
+		if host == "" {
+			return nil, fmt.Errorf("ghttp: line %d: no Host header for %q", start, url)
+		}
+		url = "http://" + host + url
+	case !strings.Contains(url, "://"):
+		url = "http://" + url
+	}
+
+	req, err := NewRequest(method, url)
+	if err != nil {
+		return nil, fmt.Errorf("ghttp: line %d: %s", start, err.Error())
+	}
+	if host != "" && host != req.URL.Host {
+		req.Host = host
+	}
+	for _, h := range headers {
+		req.Header.Add(h[0], h[1])
+	}
+
+	body := strings.TrimRight(strings.Join(lines[i:], "\n"), "\n")
+	if body != "" {
+		if body, err = substitute(start+i, body); err != nil {
+			return nil, err
+		}
+		req.SetBody(strings.NewReader(body))
+	}
+	return req, nil
+}
+
+// WriteHTTPFile writes reqs to w in the format of .http files, separated by ### lines,
+// which can be parsed by ParseHTTPFile.
+// The request bodies are still readable after written.
+func WriteHTTPFile(w io.Writer, reqs ...*Request) error {
+	bw := bufio.NewWriter(w)
+	for i, req := range reqs {
+		if i > 0 {
+			io.WriteString(bw, "\n"+httpFileSeparator+"\n\n")
+		}
+
+		writeRequestLine(req, bw, req.URL.String(), "", "\n")
+		writeRequestHeaders(req, bw, "", "\n")
+		if bodyEmpty(req.Body) {
+			continue
+		}
+
+		if err := req.bufferBody(); err != nil {
+			return err
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		bw.Write(b)
+		io.WriteString(bw, "\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,111 @@
+package ghttp
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dummyHTTPFile = `# Requests of httpbin
+### Get
+GET https://{{host}}/get
+    ?page=1
+    &size={{ size }}
+Accept: application/json
+
+### Create
+// The body contains variables too
+POST /post HTTP/1.1
+Host: {{host}}
+Content-Type: application/json
+Authorization: Bearer {{token}}
+
+{
+  "name": "{{name}}"
+}
+
+
+###
+httpbin.org/anything
+`
+
+func TestParseHTTPFile(t *testing.T) {
+	reqs, err := ParseHTTPFile(strings.NewReader(dummyHTTPFile), KV{
+		"host":  "httpbin.org",
+		"size":  10,
+		"token": "secret",
+		"name":  "ghttp",
+	})
+	require.NoError(t, err)
+	require.Len(t, reqs, 3)
+
+	assert.Equal(t, MethodGet, reqs[0].Method)
+	assert.Equal(t, "https://httpbin.org/get?page=1&size=10", reqs[0].URL.String())
+	assert.Equal(t, "application/json", reqs[0].Header.Get("Accept"))
+	assert.Nil(t, reqs[0].Body)
+
+	assert.Equal(t, MethodPost, reqs[1].Method)
+	assert.Equal(t, "http://httpbin.org/post", reqs[1].URL.String())
+	assert.Equal(t, "Bearer secret", reqs[1].Header.Get("Authorization"))
+	b, err := ioutil.ReadAll(reqs[1].Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "{\n  \"name\": \"ghttp\"\n}", string(b))
+	}
+
+	assert.Equal(t, MethodGet, reqs[2].Method)
+	assert.Equal(t, "http://httpbin.org/anything", reqs[2].URL.String())
+
+	_, err = ParseHTTPFile(strings.NewReader(dummyHTTPFile), KV{"host": "httpbin.org"})
+	assert.EqualError(t, err, `ghttp: line 5: undefined variable "size"`)
+
+	_, err = ParseHTTPFile(strings.NewReader("GET /get\nAccept: */*"), nil)
+	assert.EqualError(t, err, `ghttp: line 1: no Host header for "/get"`)
+
+	_, err = ParseHTTPFile(strings.NewReader("### a\nGET https://httpbin.org/get\nAccept\n"), nil)
+	assert.EqualError(t, err, `ghttp: line 3: invalid header "Accept"`)
+}
+
+func TestWriteHTTPFile(t *testing.T) {
+	req1, _ := NewRequest(MethodGet, "https://httpbin.org/get?a=1")
+	req1.Header.Set("Accept", "application/json")
+	req1.Header.Set("X-Foo", "bar")
+	req2, _ := NewRequest(MethodPost, "https://httpbin.org/post")
+	req2.SetText("hello world")
+
+	var sb strings.Builder
+	require.NoError(t, WriteHTTPFile(&sb, req1, req2))
+	want := "" +
+		"GET https://httpbin.org/get?a=1 HTTP/1.1\n" +
+		"Host: httpbin.org\n" +
+		"Accept: application/json\n" +
+		"X-Foo: bar\n" +
+		"\n" +
+		"\n" +
+		"###\n" +
+		"\n" +
+		"POST https://httpbin.org/post HTTP/1.1\n" +
+		"Host: httpbin.org\n" +
+		"Content-Type: text/plain; charset=utf-8\n" +
+		"\n" +
+		"hello world\n"
+	assert.Equal(t, want, sb.String())
+
+	reqs, err := ParseHTTPFile(strings.NewReader(sb.String()), nil)
+	require.NoError(t, err)
+	if assert.Len(t, reqs, 2) {
+		assert.Equal(t, req1.URL.String(), reqs[0].URL.String())
+		assert.Equal(t, req1.Header, reqs[0].Header)
+		assert.Equal(t, req2.Method, reqs[1].Method)
+		b, _ := ioutil.ReadAll(reqs[1].Body)
+		assert.Equal(t, "hello world", string(b))
+	}
+
+	// The body is still readable
+	b, err := ioutil.ReadAll(req2.Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello world", string(b))
+	}
+}
@@ -0,0 +1,202 @@
+package ghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/winterssy/gjson"
+)
+
+type (
+	// JSONStream decodes a stream of JSON values from a response body one at a time,
+	// without buffering the whole body.
+	// It's created by Response.JSONStream for JSON Lines (NDJSON) and concatenated values,
+	// or by Response.JSONArrayStream for the elements of a top-level JSON array.
+	JSONStream struct {
+		resp   *Response
+		ctx    context.Context
+		cr     *countingReader
+		dec    *gjson.Decoder
+		array  bool
+		opened bool
+		index  int
+		err    error
+	}
+
+	// JSONStreamError records an error and the position of the value that caused it.
+	JSONStreamError struct {
+		// Index is the zero-based index of the value in the stream.
+		Index int
+
+		// Line is the one-based line number where the value starts.
+		Line int
+
+		// Offset is the byte offset where the value starts.
+		Offset int64
+
+		Err error
+	}
+
+	// Count the bytes and lines read from r.
+	countingReader struct {
+		r     io.Reader
+		n     int64
+		lines int
+	}
+)
+
+// Error implements error interface.
+func (e *JSONStreamError) Error() string {
+	return fmt.Sprintf("ghttp: decode JSON value %d at line %d, offset %d: %s", e.Index, e.Line, e.Offset, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *JSONStreamError) Unwrap() error {
+	return e.Err
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	cr.lines += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
+
+// JSONStream returns a JSONStream that decodes the JSON values in resp's body one by one,
+// which are separated by newlines (JSON Lines, NDJSON) or any other whitespace.
+func (resp *Response) JSONStream(opts ...func(dec *gjson.Decoder)) *JSONStream {
+	return newJSONStream(resp, false, opts)
+}
+
+// JSONArrayStream is like JSONStream, but it decodes the elements of the top-level JSON array
+// in resp's body one by one.
+func (resp *Response) JSONArrayStream(opts ...func(dec *gjson.Decoder)) *JSONStream {
+	return newJSONStream(resp, true, opts)
+}
+
+func newJSONStream(resp *Response, array bool, opts []func(dec *gjson.Decoder)) *JSONStream {
+	js := &JSONStream{
+		resp:  resp,
+		ctx:   context.Background(),
+		cr:    &countingReader{r: resp.Body},
+		array: array,
+	}
+	if resp.Request != nil {
+		js.ctx = resp.Request.Context()
+	}
+	js.dec = gjson.NewDecoder(js.cr, opts...)
+	return js
+}
+
+// Decode decodes the next JSON value into v.
+// It returns io.EOF if there are no more values, and the body is closed once the stream ends or fails.
+// The decoding errors are reported as *JSONStreamError.
+func (js *JSONStream) Decode(v interface{}) error {
+	if js.err != nil {
+		return js.err
+	}
+	if err := js.ctx.Err(); err != nil {
+		return js.stop(err)
+	}
+
+	if js.array && !js.opened {
+		if err := js.openArray(); err != nil {
+			return js.stop(err)
+		}
+	}
+	if !js.dec.More() {
+		return js.stop(js.end())
+	}
+
+	line, offset := js.position()
+	if err := js.dec.Decode(v); err != nil {
+		if ctxErr := js.ctx.Err(); ctxErr != nil {
+			return js.stop(ctxErr)
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return js.stop(&JSONStreamError{Index: js.index, Line: line, Offset: offset, Err: err})
+	}
+	js.index++
+	return nil
+}
+
+// H is like Decode, but it decodes the next JSON value into an H instance.
+func (js *JSONStream) H() (H, error) {
+	var h H
+	return h, js.Decode(&h)
+}
+
+// Close closes the response body, it stops the stream.
+func (js *JSONStream) Close() error {
+	if js.err != nil {
+		return nil
+	}
+	js.err = io.EOF
+	return js.resp.Body.Close()
+}
+
+func (js *JSONStream) openArray() error {
+	line, offset := js.position()
+	token, err := js.dec.Token()
+	if err == nil && token != json.Delim('[') {
+		err = fmt.Errorf("expected the beginning of an array, got %v", token)
+	}
+	if err != nil {
+		return &JSONStreamError{Index: js.index, Line: line, Offset: offset, Err: err}
+	}
+	js.opened = true
+	return nil
+}
+
+// Check the end of the stream, the array must be closed and followed by nothing but whitespace.
+func (js *JSONStream) end() error {
+	if js.array {
+		line, offset := js.position()
+		if _, err := js.dec.Token(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return &JSONStreamError{Index: js.index, Line: line, Offset: offset, Err: err}
+		}
+		if js.dec.More() {
+			line, offset = js.position()
+			return &JSONStreamError{
+				Index:  js.index,
+				Line:   line,
+				Offset: offset,
+				Err:    errors.New("unexpected data after the array"),
+			}
+		}
+	}
+	return io.EOF
+}
+
+// Return the line number and the byte offset of the next token,
+// the decoder's buffered data is excluded from the bytes read.
+func (js *JSONStream) position() (int, int64) {
+	var buffered bytes.Buffer
+	buffered.ReadFrom(js.dec.Buffered())
+	b := buffered.Bytes()
+	cutset := " \t\r\n"
+	if js.array {
+		// The separator before an element is consumed along with it
+		cutset += ","
+	}
+	skipped := len(b) - len(bytes.TrimLeft(b, cutset))
+	offset := js.cr.n - int64(len(b)) + int64(skipped)
+	line := js.cr.lines - bytes.Count(b[skipped:], []byte{'\n'}) + 1
+	return line, offset
+}
+
+func (js *JSONStream) stop(err error) error {
+	js.err = err
+	js.resp.Body.Close()
+	js.resp.bodyRead()
+	return err
+}
@@ -0,0 +1,168 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newJSONStreamResponse(body string) *Response {
+	return &Response{
+		Response: &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestResponse_JSONStream(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	resp := newJSONStreamResponse("{\"id\":1,\"name\":\"a\"}\n\n{\"id\":2,\"name\":\"b\"}\r\n{\"id\":3,\"name\":\"c\"}")
+	js := resp.JSONStream()
+	var items []item
+	for {
+		var v item
+		err := js.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		items = append(items, v)
+	}
+	assert.Equal(t, []item{{1, "a"}, {2, "b"}, {3, "c"}}, items)
+	assert.Equal(t, io.EOF, js.Decode(new(item)))
+	assert.NoError(t, js.Close())
+
+	resp = newJSONStreamResponse(`{"msg":"hello"} {"msg":"world"}`)
+	js = resp.JSONStream()
+	h, err := js.H()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", h.GetString("msg"))
+	h, err = js.H()
+	require.NoError(t, err)
+	assert.Equal(t, "world", h.GetString("msg"))
+	_, err = js.H()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestResponse_JSONStreamError(t *testing.T) {
+	resp := newJSONStreamResponse("{\"id\":1}\n{\"id\":2}\n  {\"id\":,}\n{\"id\":4}\n")
+	js := resp.JSONStream()
+	var v H
+	require.NoError(t, js.Decode(&v))
+	require.NoError(t, js.Decode(&v))
+	err := js.Decode(&v)
+	var jsErr *JSONStreamError
+	require.True(t, errors.As(err, &jsErr))
+	assert.Equal(t, 2, jsErr.Index)
+	assert.Equal(t, 3, jsErr.Line)
+	assert.Equal(t, int64(20), jsErr.Offset)
+	assert.True(t, strings.HasPrefix(err.Error(), "ghttp: decode JSON value 2 at line 3, offset 20: "))
+	assert.Equal(t, err, js.Decode(&v))
+
+	resp = newJSONStreamResponse(`{"id":1}{"id":`)
+	js = resp.JSONStream()
+	require.NoError(t, js.Decode(&v))
+	err = js.Decode(&v)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestResponse_JSONArrayStream(t *testing.T) {
+	resp := newJSONStreamResponse("[\n  {\"id\": 1},\n  {\"id\": 2},\n  {\"id\": 3}\n]\n")
+	js := resp.JSONArrayStream()
+	var ids []int
+	for {
+		var v struct {
+			ID int `json:"id"`
+		}
+		err := js.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		ids = append(ids, v.ID)
+	}
+	assert.Equal(t, []int{1, 2, 3}, ids)
+
+	resp = newJSONStreamResponse("[]")
+	_, err := resp.JSONArrayStream().H()
+	assert.Equal(t, io.EOF, err)
+
+	var jsErr *JSONStreamError
+	resp = newJSONStreamResponse(`{"id":1}`)
+	_, err = resp.JSONArrayStream().H()
+	require.True(t, errors.As(err, &jsErr))
+	assert.EqualError(t, err, "ghttp: decode JSON value 0 at line 1, offset 0: expected the beginning of an array, got {")
+
+	resp = newJSONStreamResponse("[1,\n 2,\n x]")
+	js = resp.JSONArrayStream()
+	var n int
+	require.NoError(t, js.Decode(&n))
+	require.NoError(t, js.Decode(&n))
+	err = js.Decode(&n)
+	require.True(t, errors.As(err, &jsErr))
+	assert.Equal(t, 2, jsErr.Index)
+	assert.Equal(t, 3, jsErr.Line)
+	assert.Equal(t, int64(9), jsErr.Offset)
+
+	resp = newJSONStreamResponse("[1] 2")
+	js = resp.JSONArrayStream()
+	require.NoError(t, js.Decode(&n))
+	err = js.Decode(&n)
+	assert.EqualError(t, err, "ghttp: decode JSON value 1 at line 1, offset 4: unexpected data after the array")
+
+	resp = newJSONStreamResponse("[1, 2")
+	js = resp.JSONArrayStream()
+	require.NoError(t, js.Decode(&n))
+	require.NoError(t, js.Decode(&n))
+	err = js.Decode(&n)
+	assert.EqualError(t, err, "ghttp: decode JSON value 2 at line 1, offset 5: unexpected end of JSON input")
+}
+
+func TestResponse_JSONStreamCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "{\"n\":%d}\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := New()
+	resp, err := client.Get(ts.URL, WithContext(ctx))
+	require.NoError(t, err)
+
+	js := resp.JSONStream()
+	var v struct {
+		N int `json:"n"`
+	}
+	require.NoError(t, js.Decode(&v))
+	assert.Equal(t, 0, v.N)
+	cancel()
+	for err == nil {
+		err = js.Decode(&v)
+	}
+	assert.Equal(t, context.Canceled, err)
+}
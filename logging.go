@@ -0,0 +1,337 @@
+package ghttp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Levels of log events.
+const (
+	// LogLevelInfo is the level of a successful request.
+	LogLevelInfo LogLevel = iota
+
+	// LogLevelWarn is the level of a request which is slower than the slow threshold.
+	LogLevelWarn
+
+	// LogLevelError is the level of a request which fails or gets a 5xx response.
+	LogLevelError
+)
+
+var (
+	// Query params masked by default, compared case-insensitively.
+	defaultRedactQueryParams = []string{
+		"access_token",
+		"api_key",
+		"apikey",
+		"key",
+		"password",
+		"secret",
+		"signature",
+		"token",
+	}
+
+	// Headers masked whenever headers are logged.
+	defaultRedactHeaders = []string{
+		"Authorization",
+		"Cookie",
+		"Proxy-Authorization",
+		"Set-Cookie",
+	}
+)
+
+type (
+	// LogLevel is the level of a log event.
+	LogLevel int
+
+	// LogEvent is a structured event describes a request and its outcome.
+	LogEvent struct {
+		Level         LogLevel      `json:"level"`
+		Method        string        `json:"method"`
+		URL           string        `json:"url"`
+		StatusCode    int           `json:"status_code,omitempty"`
+		Duration      time.Duration `json:"duration"`
+		RequestBytes  int64         `json:"request_bytes"`
+		ResponseBytes int64         `json:"response_bytes"`
+		Retries       int           `json:"retries"`
+		Err           error         `json:"-"`
+
+		// The following fields are only set if enabled by the log options.
+		RequestHeader  http.Header `json:"request_header,omitempty"`
+		ResponseHeader http.Header `json:"response_header,omitempty"`
+		RequestBody    string      `json:"request_body,omitempty"`
+		ResponseBody   string      `json:"response_body,omitempty"`
+	}
+
+	// Logger is the interface that logs the events of requests.
+	// Implementations must be safe for concurrent use by multiple goroutines.
+	Logger interface {
+		Log(event *LogEvent)
+	}
+
+	// LoggerFunc is an adapter to allow the use of ordinary functions as Logger.
+	LoggerFunc func(event *LogEvent)
+
+	// LogOption configures the logging enabled by Client.EnableLogging.
+	LogOption func(l *logging)
+
+	stdLogger struct {
+		l *log.Logger
+	}
+
+	kvLogger struct {
+		log func(keyvals ...interface{})
+	}
+
+	logging struct {
+		logger        Logger
+		level         LogLevel
+		slowThreshold time.Duration
+		redactor      *redactor
+		headers       bool
+		body          bool
+		maxBodySize   int64
+		redactBody    func(contentType string, body []byte) []byte
+	}
+)
+
+// String implements fmt.Stringer interface.
+func (ll LogLevel) String() string {
+	switch ll {
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Log implements Logger interface.
+func (f LoggerFunc) Log(event *LogEvent) {
+	f(event)
+}
+
+// KeyValues returns the fields of e as alternating keys and values, the optional ones are omitted
+// if not set.
+func (e *LogEvent) KeyValues() []interface{} {
+	kvs := []interface{}{
+		"level", e.Level.String(),
+		"method", e.Method,
+		"url", e.URL,
+		"status", e.StatusCode,
+		"duration", e.Duration,
+		"request_bytes", e.RequestBytes,
+		"response_bytes", e.ResponseBytes,
+		"retries", e.Retries,
+	}
+	if e.Err != nil {
+		kvs = append(kvs, "error", e.Err.Error())
+	}
+	if e.RequestHeader != nil {
+		kvs = append(kvs, "request_header", e.RequestHeader)
+	}
+	if e.ResponseHeader != nil {
+		kvs = append(kvs, "response_header", e.ResponseHeader)
+	}
+	if e.RequestBody != "" {
+		kvs = append(kvs, "request_body", e.RequestBody)
+	}
+	if e.ResponseBody != "" {
+		kvs = append(kvs, "response_body", e.ResponseBody)
+	}
+	return kvs
+}
+
+// String returns e in logfmt, implements fmt.Stringer interface.
+func (e *LogEvent) String() string {
+	var sb strings.Builder
+	kvs := e.KeyValues()
+	for i := 0; i < len(kvs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(kvs[i].(string))
+		sb.WriteByte('=')
+		v := fmt.Sprint(kvs[i+1])
+		if v == "" || strings.ContainsAny(v, " =\"\t\r\n") {
+			v = strconv.Quote(v)
+		}
+		sb.WriteString(v)
+	}
+	return sb.String()
+}
+
+// NewStdLogger returns a Logger which writes the events to l in logfmt.
+// If l is nil, the standard logger of the log package is used.
+func NewStdLogger(l *log.Logger) Logger {
+	return &stdLogger{l: l}
+}
+
+// Log implements Logger interface.
+func (sl *stdLogger) Log(event *LogEvent) {
+	if sl.l == nil {
+		log.Print("ghttp: ", event.String())
+		return
+	}
+	sl.l.Print("ghttp: ", event.String())
+}
+
+// NewKVLogger returns a Logger which passes the fields of the events as alternating keys and values
+// to log, e.g. the Log method of go-kit's logger or a closure calls zap's SugaredLogger.Infow.
+func NewKVLogger(log func(keyvals ...interface{})) Logger {
+	return &kvLogger{log: log}
+}
+
+// Log implements Logger interface.
+func (kl *kvLogger) Log(event *LogEvent) {
+	kl.log(event.KeyValues()...)
+}
+
+func newLogging(logger Logger, opts []LogOption) *logging {
+	l := &logging{
+		logger:   logger,
+		redactor: newRedactor(nil),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *logging) wrap(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		event := &LogEvent{
+			Method:       req.Method,
+			URL:          l.redactURL(req.URL),
+			RequestBytes: req.ContentLength,
+		}
+		if l.headers {
+			event.RequestHeader = l.redactor.redactHeader(req.Header)
+		}
+		if l.body && !bodyEmpty(req.Body) {
+			b, err := req.peekBody()
+			if err != nil {
+				return nil, err
+			}
+			event.RequestBytes = int64(len(b))
+			event.RequestBody = l.formatBody(req.Header.Get("Content-Type"), b)
+		}
+
+		start := time.Now()
+		resp, err := next(req)
+		event.Duration = time.Since(start)
+		event.Err = err
+		l.readResponse(event, resp)
+
+		var retryErr *RetryError
+		switch {
+		case resp != nil && len(resp.attempts) > 0:
+			event.Retries = len(resp.attempts) - 1
+		case errors.As(err, &retryErr) && len(retryErr.Attempts) > 0:
+			event.Retries = len(retryErr.Attempts) - 1
+		}
+
+		switch {
+		case err != nil || event.StatusCode >= http.StatusInternalServerError:
+			event.Level = LogLevelError
+		case l.slowThreshold > 0 && event.Duration >= l.slowThreshold:
+			event.Level = LogLevelWarn
+		}
+		if event.Level >= l.level {
+			l.logger.Log(event)
+		}
+		return resp, err
+	}
+}
+
+func (l *logging) readResponse(event *LogEvent, resp *Response) {
+	if resp == nil || resp.Response == nil {
+		return
+	}
+
+	event.StatusCode = resp.StatusCode
+	event.ResponseBytes = resp.ContentLength
+	if l.headers {
+		event.ResponseHeader = l.redactor.redactHeader(resp.Header)
+	}
+	if !l.body || bodyEmpty(resp.Body) {
+		return
+	}
+
+	// Only read the logged part, the rest is left to the caller
+	if b, err := resp.peekBody(l.maxBodySize); err == nil {
+		if event.ResponseBytes < 0 && (l.maxBodySize <= 0 || int64(len(b)) <= l.maxBodySize) {
+			event.ResponseBytes = int64(len(b))
+		}
+		event.ResponseBody = l.formatBody(resp.Header.Get("Content-Type"), b)
+	}
+}
+
+func (l *logging) redactURL(u *neturl.URL) string {
+	return maskQuery(u, l.redactor.redactQuery).String()
+}
+
+func (l *logging) formatBody(contentType string, b []byte) string {
+	if l.redactBody != nil {
+		b = l.redactBody(contentType, b)
+	}
+	b, truncated := truncateBody(b, l.maxBodySize)
+	s := string(b)
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// WithLogLevel is a log option that specifies the minimum level of the events to log,
+// e.g. LogLevelWarn to log the failed and slow requests only.
+// By default is LogLevelInfo, that is, all requests are logged.
+func WithLogLevel(level LogLevel) LogOption {
+	return func(l *logging) {
+		l.level = level
+	}
+}
+
+// WithLogSlowThreshold is a log option that specifies the duration over which a request
+// is considered slow and logged at LogLevelWarn.
+func WithLogSlowThreshold(d time.Duration) LogOption {
+	return func(l *logging) {
+		l.slowThreshold = d
+	}
+}
+
+// WithLogRedactQuery is a log option that specifies more query params to mask in the logged URL,
+// see WithRedactQuery for more details.
+func WithLogRedactQuery(keys ...string) LogOption {
+	return func(l *logging) {
+		WithRedactQuery(keys...)(l.redactor)
+	}
+}
+
+// WithLogHeaders is a log option that logs the request and response headers,
+// with the values of the specified headers masked, see WithRedactHeaders for more details.
+func WithLogHeaders(redactKeys ...string) LogOption {
+	return func(l *logging) {
+		l.headers = true
+		WithRedactHeaders(redactKeys...)(l.redactor)
+	}
+}
+
+// WithLogBody is a log option that logs the request and response bodies up to maxSize bytes each,
+// while maxSize less than or equal to zero means no limit.
+// If redact isn't nil, it's called to mask the secrets in a body before logging.
+func WithLogBody(maxSize int64, redact func(contentType string, body []byte) []byte) LogOption {
+	return func(l *logging) {
+		l.body = true
+		l.maxBodySize = maxSize
+		l.redactBody = redact
+	}
+}
@@ -0,0 +1,146 @@
+package ghttp
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogLevel_String(t *testing.T) {
+	assert.Equal(t, "info", LogLevelInfo.String())
+	assert.Equal(t, "warn", LogLevelWarn.String())
+	assert.Equal(t, "error", LogLevelError.String())
+	assert.Equal(t, "unknown", LogLevel(-1).String())
+}
+
+func TestClient_EnableLogging(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte(`{"password":"secret","name":"ghttp"}`))
+	}))
+	defer ts.Close()
+
+	var (
+		mu     sync.Mutex
+		events []*LogEvent
+	)
+	logger := LoggerFunc(func(event *LogEvent) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+
+	client := New()
+	client.EnableLogging(logger,
+		WithLogRedactQuery("sig"),
+		WithLogHeaders("X-Api-Key"),
+		WithLogBody(24, func(contentType string, body []byte) []byte {
+			return bytes.Replace(body, []byte("secret"), []byte("******"), -1)
+		}),
+	)
+
+	resp, err := client.Post(ts.URL+"/post?token=abc&sig=def&page=1",
+		WithHeaders(Headers{"X-Api-Key": "key", "Accept": "application/json"}),
+		WithText("my secret"),
+	)
+	require.NoError(t, err)
+	// The body is still readable after logged
+	result, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"password":"secret","name":"ghttp"}`, result)
+	}
+
+	require.Len(t, events, 1)
+	event := events[0]
+	assert.Equal(t, LogLevelInfo, event.Level)
+	assert.Equal(t, MethodPost, event.Method)
+	assert.Equal(t, ts.URL+"/post?page=1&sig=REDACTED&token=REDACTED", event.URL)
+	assert.Equal(t, http.StatusOK, event.StatusCode)
+	assert.Equal(t, int64(9), event.RequestBytes)
+	assert.Equal(t, int64(36), event.ResponseBytes)
+	assert.Equal(t, "REDACTED", event.RequestHeader.Get("X-Api-Key"))
+	assert.Equal(t, "application/json", event.RequestHeader.Get("Accept"))
+	assert.Equal(t, "REDACTED", event.ResponseHeader.Get("Set-Cookie"))
+	assert.Equal(t, "my ******", event.RequestBody)
+	assert.Equal(t, `{"password":"******","na...(truncated)`, event.ResponseBody)
+	assert.True(t, event.Duration > 0)
+
+	events = nil
+	client = New()
+	client.EnableLogging(logger, WithLogLevel(LogLevelWarn), WithLogSlowThreshold(30*time.Millisecond))
+	_, err = client.Get(ts.URL)
+	require.NoError(t, err)
+	_, err = client.Get(ts.URL + "/slow")
+	require.NoError(t, err)
+	_, err = client.Get(ts.URL+"/error",
+		WithRetrier(WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode == http.StatusInternalServerError
+			}),
+		),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, events, 2)
+	assert.Equal(t, LogLevelWarn, events[0].Level)
+	assert.Equal(t, LogLevelError, events[1].Level)
+	assert.Equal(t, http.StatusInternalServerError, events[1].StatusCode)
+	assert.Equal(t, 3, events[1].Retries)
+	assert.Nil(t, events[1].RequestHeader)
+}
+
+func TestLogEvent_String(t *testing.T) {
+	event := &LogEvent{
+		Level:         LogLevelError,
+		Method:        MethodGet,
+		URL:           "https://httpbin.org/get",
+		Duration:      time.Second,
+		RequestBytes:  0,
+		ResponseBytes: -1,
+		Err:           errAccessDummyBody,
+	}
+	want := `level=error method=GET url=https://httpbin.org/get status=0 duration=1s request_bytes=0 ` +
+		`response_bytes=-1 retries=0 error="` + errAccessDummyBody.Error() + `"`
+	assert.Equal(t, want, event.String())
+}
+
+func TestNewStdLogger(t *testing.T) {
+	var sb strings.Builder
+	logger := NewStdLogger(log.New(&sb, "", 0))
+	logger.Log(&LogEvent{Method: MethodGet, URL: "https://httpbin.org/get", StatusCode: http.StatusOK})
+	assert.Equal(t, "ghttp: level=info method=GET url=https://httpbin.org/get status=200 duration=0s "+
+		"request_bytes=0 response_bytes=0 retries=0\n", sb.String())
+}
+
+func TestNewKVLogger(t *testing.T) {
+	var keyvals []interface{}
+	logger := NewKVLogger(func(kvs ...interface{}) {
+		keyvals = kvs
+	})
+	logger.Log(&LogEvent{Method: MethodGet, RequestBody: "hello"})
+	assert.Equal(t, []interface{}{
+		"level", "info",
+		"method", MethodGet,
+		"url", "",
+		"status", 0,
+		"duration", time.Duration(0),
+		"request_bytes", int64(0),
+		"response_bytes", int64(0),
+		"retries", 0,
+		"request_body", "hello",
+	}, keyvals)
+}
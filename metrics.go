@@ -0,0 +1,435 @@
+package ghttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMetricsNamespace = "ghttp"
+
+	// The path label of the requests which don't match any URL template.
+	unmatchedPathLabel = "other"
+)
+
+var (
+	// The same as the default buckets of the Prometheus client.
+	defaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	metricsLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+type (
+	// MetricLabels are the labels of the metrics of a request.
+	// Path is empty unless a path label is enabled by WithMetricsURLTemplates or WithMetricsPathLabel,
+	// Status is the status code of the response or "error" if no response, and it's empty before
+	// the request finishes.
+	MetricLabels struct {
+		Host   string
+		Method string
+		Path   string
+		Status string
+	}
+
+	// Metrics is the interface that collects the metrics of requests.
+	// Implementations must be safe for concurrent use by multiple goroutines.
+	Metrics interface {
+		// RequestStarted is called before a request is sent.
+		RequestStarted(labels MetricLabels)
+
+		// RequestFinished is called after the response headers of a request are received
+		// or the request fails, sentBytes is the length of the request body if known.
+		RequestFinished(labels MetricLabels, duration time.Duration, sentBytes int64)
+
+		// RequestRetried is called before each retry of a request.
+		RequestRetried(labels MetricLabels)
+
+		// ResponseBodyRead is called when the response body of a request is read to EOF or closed,
+		// with the number of bytes read.
+		ResponseBodyRead(labels MetricLabels, n int64)
+	}
+
+	// MetricsOption configures the metrics enabled by Client.EnableMetrics.
+	MetricsOption func(mh *metricsHook)
+
+	metricsHook struct {
+		metrics   Metrics
+		pathLabel func(req *Request) string
+	}
+
+	meteredBody struct {
+		io.ReadCloser
+		n    int64
+		once sync.Once
+		done func(n int64)
+	}
+
+	// PrometheusOption configures a PrometheusMetrics.
+	PrometheusOption func(pm *PrometheusMetrics)
+
+	// PrometheusMetrics is an in-process Metrics implementation which can be scraped by Prometheus.
+	// It implements http.Handler interface to serve the metrics in the Prometheus text format.
+	PrometheusMetrics struct {
+		namespace     string
+		buckets       []float64
+		mu            sync.Mutex
+		inFlight      map[MetricLabels]int64
+		durations     map[MetricLabels]*histogram
+		retries       map[MetricLabels]uint64
+		sentBytes     map[MetricLabels]int64
+		receivedBytes map[MetricLabels]int64
+	}
+
+	histogram struct {
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+)
+
+func newMetricsHook(metrics Metrics, opts []MetricsOption) *metricsHook {
+	mh := &metricsHook{metrics: metrics}
+	for _, opt := range opts {
+		opt(mh)
+	}
+	return mh
+}
+
+// WithMetricsURLTemplates is a metrics option that labels the requests with the first template
+// matches the URL path, e.g. "/users/{id}" matches "/users/1", a segment in braces matches any
+// non-empty segment. The requests which don't match any template are labeled "other".
+// It keeps the label cardinality low, by default there's no path label.
+func WithMetricsURLTemplates(templates ...string) MetricsOption {
+	segments := make([][]string, len(templates))
+	for i, template := range templates {
+		segments[i] = strings.Split(template, "/")
+	}
+	return WithMetricsPathLabel(func(req *Request) string {
+		path := strings.Split(req.URL.Path, "/")
+		for i, template := range segments {
+			if matchURLTemplate(template, path) {
+				return templates[i]
+			}
+		}
+		return unmatchedPathLabel
+	})
+}
+
+// WithMetricsPathLabel is a metrics option that specifies a function to label the requests by path.
+// The function must return a bounded set of values to keep the label cardinality low.
+func WithMetricsPathLabel(pathLabel func(req *Request) string) MetricsOption {
+	return func(mh *metricsHook) {
+		mh.pathLabel = pathLabel
+	}
+}
+
+func matchURLTemplate(template []string, path []string) bool {
+	if len(template) != len(path) {
+		return false
+	}
+	for i, segment := range template {
+		isParam := strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+		if isParam && path[i] == "" || !isParam && segment != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (mh *metricsHook) labels(req *Request) MetricLabels {
+	labels := MetricLabels{
+		Host:   req.URL.Host,
+		Method: req.Method,
+	}
+	if mh.pathLabel != nil {
+		labels.Path = mh.pathLabel(req)
+	}
+	return labels
+}
+
+func (mh *metricsHook) wrap(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		labels := mh.labels(req)
+		var sentBytes int64
+		if req.ContentLength > 0 {
+			sentBytes = req.ContentLength
+		}
+
+		mh.metrics.RequestStarted(labels)
+		start := time.Now()
+		resp, err := next(req)
+		duration := time.Since(start)
+
+		labels.Status = "error"
+		if resp != nil && resp.Response != nil {
+			labels.Status = strconv.Itoa(resp.StatusCode)
+		}
+		mh.metrics.RequestFinished(labels, duration, sentBytes)
+		if resp != nil && resp.Response != nil && !bodyEmpty(resp.Body) {
+			resp.Body = &meteredBody{
+				ReadCloser: resp.Body,
+				done: func(n int64) {
+					mh.metrics.ResponseBodyRead(labels, n)
+				},
+			}
+		}
+		return resp, err
+	}
+}
+
+// Every attempt after the first one is a retry.
+func (mh *metricsHook) wrapAttempt(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		if req.AttemptNum() > 0 {
+			mh.metrics.RequestRetried(mh.labels(req))
+		}
+		return next(req)
+	}
+}
+
+// Read implements io.Reader interface.
+func (mb *meteredBody) Read(p []byte) (int, error) {
+	n, err := mb.ReadCloser.Read(p)
+	mb.n += int64(n)
+	if err == io.EOF {
+		mb.once.Do(func() { mb.done(mb.n) })
+	}
+	return n, err
+}
+
+// Close implements io.Closer interface.
+func (mb *meteredBody) Close() error {
+	err := mb.ReadCloser.Close()
+	mb.once.Do(func() { mb.done(mb.n) })
+	return err
+}
+
+// NewPrometheusMetrics returns a new PrometheusMetrics.
+func NewPrometheusMetrics(opts ...PrometheusOption) *PrometheusMetrics {
+	pm := &PrometheusMetrics{
+		namespace:     defaultMetricsNamespace,
+		buckets:       defaultMetricsBuckets,
+		inFlight:      make(map[MetricLabels]int64),
+		durations:     make(map[MetricLabels]*histogram),
+		retries:       make(map[MetricLabels]uint64),
+		sentBytes:     make(map[MetricLabels]int64),
+		receivedBytes: make(map[MetricLabels]int64),
+	}
+	for _, opt := range opts {
+		opt(pm)
+	}
+	return pm
+}
+
+// WithPrometheusNamespace is a prometheus option that specifies the prefix of the metric names.
+// By default is "ghttp".
+func WithPrometheusNamespace(namespace string) PrometheusOption {
+	return func(pm *PrometheusMetrics) {
+		pm.namespace = namespace
+	}
+}
+
+// WithPrometheusBuckets is a prometheus option that specifies the upper bounds in seconds of
+// the buckets of the request duration histogram, in increasing order.
+// By default is the same as the Prometheus client, i.e. from 5ms to 10s.
+func WithPrometheusBuckets(buckets ...float64) PrometheusOption {
+	return func(pm *PrometheusMetrics) {
+		pm.buckets = buckets
+	}
+}
+
+// RequestStarted implements Metrics interface.
+func (pm *PrometheusMetrics) RequestStarted(labels MetricLabels) {
+	labels.Status = ""
+	pm.mu.Lock()
+	pm.inFlight[labels]++
+	pm.mu.Unlock()
+}
+
+// RequestFinished implements Metrics interface.
+func (pm *PrometheusMetrics) RequestFinished(labels MetricLabels, duration time.Duration, sentBytes int64) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	inFlightLabels := labels
+	inFlightLabels.Status = ""
+	pm.inFlight[inFlightLabels]--
+
+	h, ok := pm.durations[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(pm.buckets))}
+		pm.durations[labels] = h
+	}
+	h.observe(pm.buckets, duration.Seconds())
+	pm.sentBytes[labels] += sentBytes
+}
+
+// RequestRetried implements Metrics interface.
+func (pm *PrometheusMetrics) RequestRetried(labels MetricLabels) {
+	labels.Status = ""
+	pm.mu.Lock()
+	pm.retries[labels]++
+	pm.mu.Unlock()
+}
+
+// ResponseBodyRead implements Metrics interface.
+func (pm *PrometheusMetrics) ResponseBodyRead(labels MetricLabels, n int64) {
+	pm.mu.Lock()
+	pm.receivedBytes[labels] += n
+	pm.mu.Unlock()
+}
+
+// ServeHTTP implements http.Handler interface.
+func (pm *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = pm.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format, implements io.WriterTo interface.
+func (pm *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var bw bytes.Buffer
+	pm.mu.Lock()
+	name := pm.metricName("requests_total")
+	writeMetricHeader(&bw, name, "counter", "Total number of requests.")
+	for _, labels := range sortedMetricLabels(pm.durations) {
+		fmt.Fprintf(&bw, "%s%s %d\n", name, formatMetricLabels(labels), pm.durations[labels].count)
+	}
+
+	name = pm.metricName("request_duration_seconds")
+	writeMetricHeader(&bw, name, "histogram", "Duration of requests until the response headers are received.")
+	for _, labels := range sortedMetricLabels(pm.durations) {
+		h := pm.durations[labels]
+		for i, le := range pm.buckets {
+			fmt.Fprintf(&bw, "%s_bucket%s %d\n", name, formatMetricLabels(labels, "le", formatFloat(le)), h.counts[i])
+		}
+		fmt.Fprintf(&bw, "%s_bucket%s %d\n", name, formatMetricLabels(labels, "le", "+Inf"), h.count)
+		fmt.Fprintf(&bw, "%s_sum%s %s\n", name, formatMetricLabels(labels), formatFloat(h.sum))
+		fmt.Fprintf(&bw, "%s_count%s %d\n", name, formatMetricLabels(labels), h.count)
+	}
+
+	name = pm.metricName("requests_in_flight")
+	writeMetricHeader(&bw, name, "gauge", "Number of requests in flight.")
+	for _, labels := range sortedMetricLabels(pm.inFlight) {
+		fmt.Fprintf(&bw, "%s%s %d\n", name, formatMetricLabels(labels), pm.inFlight[labels])
+	}
+
+	name = pm.metricName("retries_total")
+	writeMetricHeader(&bw, name, "counter", "Total number of retries.")
+	for _, labels := range sortedMetricLabels(pm.retries) {
+		fmt.Fprintf(&bw, "%s%s %d\n", name, formatMetricLabels(labels), pm.retries[labels])
+	}
+
+	name = pm.metricName("request_bytes_total")
+	writeMetricHeader(&bw, name, "counter", "Total bytes of request bodies sent.")
+	for _, labels := range sortedMetricLabels(pm.sentBytes) {
+		fmt.Fprintf(&bw, "%s%s %d\n", name, formatMetricLabels(labels), pm.sentBytes[labels])
+	}
+
+	name = pm.metricName("response_bytes_total")
+	writeMetricHeader(&bw, name, "counter", "Total bytes of response bodies received.")
+	for _, labels := range sortedMetricLabels(pm.receivedBytes) {
+		fmt.Fprintf(&bw, "%s%s %d\n", name, formatMetricLabels(labels), pm.receivedBytes[labels])
+	}
+	pm.mu.Unlock()
+
+	n, err := w.Write(bw.Bytes())
+	return int64(n), err
+}
+
+func (pm *PrometheusMetrics) metricName(name string) string {
+	if pm.namespace == "" {
+		return name
+	}
+	return pm.namespace + "_" + name
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	for i, le := range buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func writeMetricHeader(w io.Writer, name string, typ string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// Return the keys of m sorted, m must be a map keyed by MetricLabels.
+func sortedMetricLabels(m interface{}) []MetricLabels {
+	var keys []MetricLabels
+	switch m := m.(type) {
+	case map[MetricLabels]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[MetricLabels]int64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[MetricLabels]uint64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case a.Host != b.Host:
+			return a.Host < b.Host
+		case a.Method != b.Method:
+			return a.Method < b.Method
+		case a.Path != b.Path:
+			return a.Path < b.Path
+		default:
+			return a.Status < b.Status
+		}
+	})
+	return keys
+}
+
+// Format labels in the Prometheus text format, the empty ones are omitted.
+// The extra labels are given as alternating names and values.
+func formatMetricLabels(labels MetricLabels, extra ...string) string {
+	pairs := []string{
+		"host", labels.Host,
+		"method", labels.Method,
+		"path", labels.Path,
+		"status", labels.Status,
+	}
+	pairs = append(pairs, extra...)
+
+	var sb strings.Builder
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteByte('{')
+		} else {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pairs[i] + `="` + metricsLabelValueReplacer.Replace(pairs[i+1]) + `"`)
+	}
+	if sb.Len() > 0 {
+		sb.WriteByte('}')
+	}
+	return sb.String()
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
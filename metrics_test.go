@@ -0,0 +1,138 @@
+package ghttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMetricsURLTemplates(t *testing.T) {
+	mh := newMetricsHook(nil, []MetricsOption{WithMetricsURLTemplates("/users/{id}", "/users/{id}/repos", "/")})
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{"/users/1", "/users/{id}"},
+		{"/users/1/repos", "/users/{id}/repos"},
+		{"/users/", "other"},
+		{"/", "/"},
+		{"/repos/1", "other"},
+	}
+	for _, testCase := range testCases {
+		req := &Request{Request: &http.Request{Method: MethodGet, URL: &neturl.URL{Host: "httpbin.org", Path: testCase.path}}}
+		assert.Equal(t, MetricLabels{Host: "httpbin.org", Method: MethodGet, Path: testCase.want}, mh.labels(req))
+	}
+}
+
+func TestClient_EnableMetrics(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && atomic.AddUint64(&counter, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("hello world"))
+	}))
+	defer ts.Close()
+
+	pm := NewPrometheusMetrics(WithPrometheusBuckets(0.5, 1))
+	client := New()
+	client.EnableMetrics(pm, WithMetricsURLTemplates("/users/{id}"))
+
+	resp, err := client.Post(ts.URL+"/users/1", WithText("ghttp"))
+	require.NoError(t, err)
+	_, err = resp.Text()
+	require.NoError(t, err)
+
+	resp, err = client.Get(ts.URL+"/flaky",
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode != http.StatusOK
+			}),
+		),
+	)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = client.Get("http://127.0.0.1:0/users/2")
+	require.Error(t, err)
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+	rec := httptest.NewRecorder()
+	pm.ServeHTTP(rec, httptest.NewRequest(MethodGet, "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	out := rec.Body.String()
+
+	assert.Contains(t, out, "# HELP ghttp_requests_total Total number of requests.\n# TYPE ghttp_requests_total counter\n")
+	assert.Contains(t, out, `ghttp_requests_total{host="`+host+`",method="GET",path="other",status="200"} 1`+"\n")
+	assert.Contains(t, out, `ghttp_requests_total{host="`+host+`",method="POST",path="/users/{id}",status="200"} 1`+"\n")
+	assert.Contains(t, out, `ghttp_requests_total{host="127.0.0.1:0",method="GET",path="/users/{id}",status="error"} 1`+"\n")
+	assert.Contains(t, out, `ghttp_request_duration_seconds_bucket{host="`+host+`",method="POST",path="/users/{id}",status="200",le="0.5"} 1`+"\n")
+	assert.Contains(t, out, `ghttp_request_duration_seconds_bucket{host="`+host+`",method="POST",path="/users/{id}",status="200",le="+Inf"} 1`+"\n")
+	assert.Contains(t, out, `ghttp_request_duration_seconds_count{host="`+host+`",method="POST",path="/users/{id}",status="200"} 1`+"\n")
+	assert.Contains(t, out, `ghttp_requests_in_flight{host="`+host+`",method="POST",path="/users/{id}"} 0`+"\n")
+	assert.Contains(t, out, `ghttp_retries_total{host="`+host+`",method="GET",path="other"} 2`+"\n")
+	assert.Contains(t, out, `ghttp_request_bytes_total{host="`+host+`",method="POST",path="/users/{id}",status="200"} 5`+"\n")
+	assert.Contains(t, out, `ghttp_response_bytes_total{host="`+host+`",method="POST",path="/users/{id}",status="200"} 11`+"\n")
+	assert.Contains(t, out, `ghttp_response_bytes_total{host="`+host+`",method="GET",path="other",status="200"} 0`+"\n")
+}
+
+func TestPrometheusMetrics_WriteTo(t *testing.T) {
+	pm := NewPrometheusMetrics(WithPrometheusNamespace(""), WithPrometheusBuckets(0.1))
+	labels := MetricLabels{Host: "httpbin.org", Method: MethodGet}
+	pm.RequestStarted(labels)
+	labels.Status = "200"
+	pm.RequestFinished(labels, 200*time.Millisecond, 0)
+	pm.ResponseBodyRead(MetricLabels{Host: `a"b\c`, Method: MethodGet, Status: "200"}, 1)
+
+	var sb strings.Builder
+	n, err := pm.WriteTo(&sb)
+	require.NoError(t, err)
+	assert.Equal(t, int64(sb.Len()), n)
+	want := `# HELP requests_total Total number of requests.
+# TYPE requests_total counter
+requests_total{host="httpbin.org",method="GET",status="200"} 1
+# HELP request_duration_seconds Duration of requests until the response headers are received.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{host="httpbin.org",method="GET",status="200",le="0.1"} 0
+request_duration_seconds_bucket{host="httpbin.org",method="GET",status="200",le="+Inf"} 1
+request_duration_seconds_sum{host="httpbin.org",method="GET",status="200"} 0.2
+request_duration_seconds_count{host="httpbin.org",method="GET",status="200"} 1
+# HELP requests_in_flight Number of requests in flight.
+# TYPE requests_in_flight gauge
+requests_in_flight{host="httpbin.org",method="GET"} 0
+# HELP retries_total Total number of retries.
+# TYPE retries_total counter
+# HELP request_bytes_total Total bytes of request bodies sent.
+# TYPE request_bytes_total counter
+request_bytes_total{host="httpbin.org",method="GET",status="200"} 0
+# HELP response_bytes_total Total bytes of response bodies received.
+# TYPE response_bytes_total counter
+response_bytes_total{host="a\"b\\c",method="GET",status="200"} 1
+`
+	assert.Equal(t, want, sb.String())
+}
+
+func TestMeteredBody(t *testing.T) {
+	var reported []int64
+	mb := &meteredBody{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("hello")),
+		done: func(n int64) {
+			reported = append(reported, n)
+		},
+	}
+	b, err := ioutil.ReadAll(mb)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+	assert.NoError(t, mb.Close())
+	assert.Equal(t, []int64{5}, reported)
+}
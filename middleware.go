@@ -0,0 +1,58 @@
+package ghttp
+
+import "errors"
+
+var (
+	// ErrNoResponse is reported when a middleware returns neither a response nor an error.
+	ErrNoResponse = errors.New("ghttp: middleware returned no response")
+)
+
+// Chain composes middlewares into one. The first middleware is the outermost,
+// that is, it's the first one to see the request and the last one to see the response.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// Compose middlewares around next like Chain, but every middleware's result is checked,
+// so that the outer ones and the retrier always get either a response or an error.
+func chainChecked(next Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = checkResponse(middlewares[i](next))
+	}
+	return next
+}
+
+// Report ErrNoResponse if next returns neither a response nor an error.
+func checkResponse(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		resp, err := next(req)
+		if err == nil && (resp == nil || resp.Response == nil) {
+			return nil, ErrNoResponse
+		}
+		return resp, err
+	}
+}
+
+// Invoke c's callbacks around next. The before request callbacks are invoked in order
+// until one of them returns an error, which stops the request without invoking any after response callback.
+// The after response callbacks are invoked in order too.
+func (c *Client) invokeCallbacks(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		for _, callback := range c.beforeRequestCallbacks {
+			if err := callback.Enter(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := next(req)
+		for _, callback := range c.afterResponseCallbacks {
+			callback.Exit(resp, err)
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,215 @@
+package ghttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dummyCallback struct {
+	err   error
+	trace *[]string
+	name  string
+}
+
+func (dc *dummyCallback) Enter(*Request) error {
+	*dc.trace = append(*dc.trace, strings.TrimSpace(dc.name+" enter"))
+	return dc.err
+}
+
+func (dc *dummyCallback) Exit(*Response, error) {
+	*dc.trace = append(*dc.trace, strings.TrimSpace(dc.name+" exit"))
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *Request) (*Response, error) {
+				trace = append(trace, name+" before")
+				resp, err := next(req)
+				trace = append(trace, name+" after")
+				return resp, err
+			}
+		}
+	}
+
+	handler := Chain(record("m1"), record("m2"))(func(req *Request) (*Response, error) {
+		trace = append(trace, "handler")
+		return &Response{}, nil
+	})
+	_, err := handler(&Request{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"m1 before", "m2 before", "handler", "m2 after", "m1 after"}, trace)
+	}
+}
+
+func TestClient_Use(t *testing.T) {
+	var counter int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter++
+		w.Header().Set("X-Middleware", r.Header.Get("X-Middleware"))
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.Use(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			req.Header.Set("X-Middleware", "client")
+			return next(req)
+		}
+	})
+
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "client", resp.Header.Get("X-Middleware"))
+
+	mock := func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			return &Response{
+				Response: &http.Response{
+					StatusCode: http.StatusTeapot,
+					Header:     http.Header{"X-Middleware": {req.Header.Get("X-Middleware")}},
+					Body:       http.NoBody,
+				},
+			}, nil
+		}
+	}
+	resp, err = client.Get(ts.URL, WithMiddlewares(mock))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	assert.Equal(t, "client", resp.Header.Get("X-Middleware"))
+	assert.Equal(t, 1, counter)
+}
+
+func TestClient_UseAttempt(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The token expires after the first attempt
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	var requests int
+	var attempts []int
+	client := New()
+	client.Use(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			requests++
+			return next(req)
+		}
+	})
+	client.UseAttempt(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			attempts = append(attempts, req.AttemptNum())
+			if req.AttemptNum() > 0 {
+				req.Header.Set("Authorization", "Bearer fresh")
+			}
+			return next(req)
+		}
+	})
+
+	var statuses []int
+	resp, err := client.Get(ts.URL,
+		WithBearerToken("stale"),
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode == http.StatusServiceUnavailable
+			}),
+		),
+		WithAttemptMiddlewares(func(next Handler) Handler {
+			return func(req *Request) (*Response, error) {
+				resp, err := next(req)
+				if err == nil {
+					statuses = append(statuses, resp.StatusCode)
+				}
+				return resp, err
+			}
+		}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, []int{0, 1}, attempts)
+	assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK}, statuses)
+	assert.Len(t, resp.Attempts(), 2)
+}
+
+func TestClient_NoResponse(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&counter, 1)
+	}))
+	defer ts.Close()
+
+	noResponse := func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			return nil, nil
+		}
+	}
+	emptyResponse := func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			return &Response{}, nil
+		}
+	}
+
+	client := New()
+	client.EnableCircuitBreaker()
+	client.EnableRetryBudget(10, 0.1)
+	client.EnableCaching(NewMemoryCache(0))
+	_, err := client.Get(ts.URL,
+		WithRetrier(WithRetryBackoff(NewConstantBackoff(time.Millisecond, false))),
+		WithAttemptMiddlewares(noResponse),
+	)
+	assert.True(t, errors.Is(err, ErrNoResponse))
+
+	_, err = client.Get(ts.URL, WithMiddlewares(emptyResponse))
+	assert.Equal(t, ErrNoResponse, err)
+
+	client.UseAttempt(emptyResponse)
+	_, err = client.Get(ts.URL)
+	assert.Equal(t, ErrNoResponse, err)
+	assert.Zero(t, atomic.LoadUint64(&counter))
+}
+
+func TestClient_RegisterCallbacks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var trace []string
+	callback := &dummyCallback{trace: &trace}
+	client := New()
+	client.RegisterAfterResponseCallbacks(callback)
+	client.RegisterBeforeRequestCallbacks(callback)
+
+	_, err := client.Get(ts.URL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"enter", "exit"}, trace)
+	}
+
+	trace = trace[:0]
+	callback.err = errAccessDummyBody
+	_, err = client.Get(ts.URL)
+	if assert.Equal(t, errAccessDummyBody, err) {
+		// No after response callback is invoked if a before request callback fails
+		assert.Equal(t, []string{"enter"}, trace)
+	}
+
+	trace = trace[:0]
+	callback.err = nil
+	client.RegisterBeforeRequestCallbacks(callback)
+	client.RegisterAfterResponseCallbacks(&dummyCallback{trace: &trace, name: "last"})
+	_, err = client.Get(ts.URL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"enter", "enter", "exit", "last exit"}, trace)
+	}
+}
@@ -0,0 +1,395 @@
+// Package mock provides a mock transport for testing the code built with ghttp.Client.
+package mock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/winterssy/gjson"
+)
+
+var (
+	// ErrNoResponder is returned by a MockTransport when a request doesn't match any expectation.
+	ErrNoResponder = errors.New("mock: no responder found")
+)
+
+type (
+	// Responder returns the response or error for a request.
+	Responder func(req *http.Request) (*http.Response, error)
+
+	// Matcher reports whether a request matches.
+	Matcher func(req *http.Request) bool
+
+	// TestingT is the interface wraps the Errorf method of *testing.T.
+	TestingT interface {
+		Errorf(format string, args ...interface{})
+	}
+
+	// Call records a request received by a MockTransport.
+	Call struct {
+		Request *http.Request
+		Body    []byte
+	}
+
+	// Expectation is a responder registered for the requests match its method and matchers.
+	Expectation struct {
+		mu        *sync.Mutex
+		method    string
+		matchers  []Matcher
+		responder Responder
+		times     int
+		calls     []*Call
+	}
+
+	// MockTransport is an http.RoundTripper which responds the requests with the registered responders
+	// instead of sending them, plug it into a client with:
+	//   client.Transport = mock.NewMockTransport()
+	MockTransport struct {
+		mu           sync.Mutex
+		expectations []*Expectation
+		calls        []*Call
+		unmatched    []*Call
+	}
+)
+
+// NewMockTransport returns a MockTransport without any expectation.
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// On registers an expectation for the requests with method and match all matchers.
+// An empty method matches any method.
+// By default the expectation responds with 200 OK and an empty body.
+func (mt *MockTransport) On(method string, matchers ...Matcher) *Expectation {
+	e := &Expectation{
+		mu:        &mt.mu,
+		method:    method,
+		matchers:  matchers,
+		responder: NewStringResponder(http.StatusOK, ""),
+	}
+	mt.mu.Lock()
+	mt.expectations = append(mt.expectations, e)
+	mt.mu.Unlock()
+	return e
+}
+
+// RoundTrip implements http.RoundTripper interface.
+// The expectations are checked in the order they're registered, the first one matches responds.
+func (mt *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	call := &Call{Request: req}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		call.Body = body
+	}
+
+	mt.mu.Lock()
+	mt.calls = append(mt.calls, call)
+	var responder Responder
+	for _, e := range mt.expectations {
+		if e.match(req) {
+			e.calls = append(e.calls, call)
+			responder = e.responder
+			break
+		}
+	}
+	if responder == nil {
+		mt.unmatched = append(mt.unmatched, call)
+	}
+	mt.mu.Unlock()
+
+	if responder == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoResponder, req.Method, req.URL)
+	}
+	return responder(req)
+}
+
+// Calls returns all requests received by mt.
+func (mt *MockTransport) Calls() []*Call {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return append([]*Call(nil), mt.calls...)
+}
+
+// AssertExpectations asserts that every expectation is called the times it expects,
+// at least once if not specified, and no request is unmatched.
+func (mt *MockTransport) AssertExpectations(t TestingT) bool {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	ok := true
+	for _, e := range mt.expectations {
+		switch n := len(e.calls); {
+		case e.times > 0 && n != e.times:
+			t.Errorf("mock: %s expected to be called %d time(s), but called %d time(s)", e, e.times, n)
+			ok = false
+		case e.times <= 0 && n == 0:
+			t.Errorf("mock: %s expected to be called, but not", e)
+			ok = false
+		}
+	}
+	for _, call := range mt.unmatched {
+		t.Errorf("mock: unexpected request %s %s", call.Request.Method, call.Request.URL)
+		ok = false
+	}
+	return ok
+}
+
+// Respond specifies the responder of e.
+func (e *Expectation) Respond(responder Responder) *Expectation {
+	e.responder = responder
+	return e
+}
+
+// Times specifies the number of times e is expected to be called.
+// Once called n times, e no longer matches, so the later expectations get the chance to respond.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Once is an alias of Times(1).
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// Calls returns the requests e responded.
+func (e *Expectation) Calls() []*Call {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Call(nil), e.calls...)
+}
+
+// String implements fmt.Stringer interface.
+func (e *Expectation) String() string {
+	method := e.method
+	if method == "" {
+		method = "*"
+	}
+	return fmt.Sprintf("expectation %s with %d matcher(s)", method, len(e.matchers))
+}
+
+func (e *Expectation) match(req *http.Request) bool {
+	if e.method != "" && e.method != req.Method {
+		return false
+	}
+	if e.times > 0 && len(e.calls) >= e.times {
+		return false
+	}
+
+	for _, m := range e.matchers {
+		if !m(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// URL returns a matcher that matches the request URL exactly, the order of query params is ignored.
+func URL(rawurl string) Matcher {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return func(req *http.Request) bool {
+			return req.URL.String() == rawurl
+		}
+	}
+
+	query := u.Query()
+	return func(req *http.Request) bool {
+		if req.URL.Scheme != u.Scheme || req.URL.Host != u.Host || req.URL.Path != u.Path {
+			return false
+		}
+
+		q := req.URL.Query()
+		return len(q) == 0 && len(query) == 0 || reflect.DeepEqual(q, query)
+	}
+}
+
+// Path returns a matcher that matches the request URL path.
+func Path(path string) Matcher {
+	return func(req *http.Request) bool {
+		return req.URL.Path == path
+	}
+}
+
+// URLRegexp returns a matcher that matches the request URL against a regular expression.
+// It panics if pattern can't be compiled.
+func URLRegexp(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return func(req *http.Request) bool {
+		return re.MatchString(req.URL.String())
+	}
+}
+
+// Query returns a matcher that matches the request with a query param key equals value.
+func Query(key string, value string) Matcher {
+	return func(req *http.Request) bool {
+		values, ok := req.URL.Query()[key]
+		if !ok {
+			return false
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Header returns a matcher that matches the request with a header key equals value.
+func Header(key string, value string) Matcher {
+	return func(req *http.Request) bool {
+		return req.Header.Get(key) == value
+	}
+}
+
+// NewBytesResponder returns a responder which responds with status and body.
+func NewBytesResponder(status int, body []byte) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		return newResponse(req, status, make(http.Header), body), nil
+	}
+}
+
+// NewStringResponder returns a responder which responds with status and body.
+func NewStringResponder(status int, body string) Responder {
+	return NewBytesResponder(status, []byte(body))
+}
+
+// NewJSONResponder returns a responder which responds with status and v encoded as JSON, e.g. a ghttp.H.
+func NewJSONResponder(status int, v interface{}) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		// Don't use gjson.Encode, its output is backed by a pooled buffer which is already freed
+		var body bytes.Buffer
+		if err := gjson.NewEncoder(&body).Encode(v); err != nil {
+			return nil, err
+		}
+
+		resp := newResponse(req, status, make(http.Header), bytes.TrimSuffix(body.Bytes(), []byte{'\n'}))
+		resp.Header.Set("Content-Type", "application/json; charset=utf-8")
+		return resp, nil
+	}
+}
+
+// NewFileResponder returns a responder which responds with status and the content of a file,
+// the file is read whenever a request is responded.
+func NewFileResponder(status int, filename string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return newResponse(req, status, make(http.Header), body), nil
+	}
+}
+
+// NewErrorResponder returns a responder which fails with err.
+func NewErrorResponder(err error) Responder {
+	return func(*http.Request) (*http.Response, error) {
+		return nil, err
+	}
+}
+
+// Sequence returns a responder which responds with responders in order,
+// the last one is repeated once they're used up.
+// It panics if no responders specified.
+func Sequence(responders ...Responder) Responder {
+	if len(responders) == 0 {
+		panic("mock: no responders specified")
+	}
+
+	var mu sync.Mutex
+	i := 0
+	return func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		r := responders[i]
+		if i < len(responders)-1 {
+			i++
+		}
+		mu.Unlock()
+		return r(req)
+	}
+}
+
+// Header returns a responder which sets a header of the responses of r.
+func (r Responder) Header(key string, value string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err == nil {
+			resp.Header.Set(key, value)
+		}
+		return resp, err
+	}
+}
+
+// Delay returns a responder which responds after d to simulate latency,
+// it fails with the context error if the request is cancelled in the meantime.
+func (r Responder) Delay(d time.Duration) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return r(req)
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// Gzip returns a responder which compresses the body of the responses of r
+// and sets the Content-Encoding header to gzip.
+func (r Responder) Gzip() Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil {
+			return resp, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		if err = zw.Close(); err != nil {
+			return nil, err
+		}
+
+		resp.Header.Set("Content-Encoding", "gzip")
+		resp.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
+		resp.Body = ioutil.NopCloser(&buf)
+		resp.ContentLength = int64(buf.Len())
+		return resp, nil
+	}
+}
+
+func newResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,157 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/winterssy/ghttp"
+	"github.com/winterssy/gjson"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockTransport(t *testing.T) {
+	mt := NewMockTransport()
+	mt.On(ghttp.MethodGet, URL("https://api.example.com/users?page=1&size=10")).
+		Respond(NewJSONResponder(http.StatusOK, ghttp.H{"page": 1})).
+		Once()
+	mt.On(ghttp.MethodGet, URLRegexp(`/users/\d+$`)).
+		Respond(NewStringResponder(http.StatusOK, "user").Header("X-Mock", "1"))
+	post := mt.On(ghttp.MethodPost, Path("/users"), Query("dry_run", "true"))
+
+	client := ghttp.New()
+	client.Transport = mt
+
+	resp, err := client.Get("https://api.example.com/users",
+		ghttp.WithQuery(ghttp.Params{"size": 10, "page": 1}),
+	)
+	require.NoError(t, err)
+	data, err := resp.H()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, data.GetNumber("page").ToInt())
+	}
+
+	resp, err = client.Get("https://api.example.com/users/123")
+	require.NoError(t, err)
+	assert.Equal(t, "1", resp.Header.Get("X-Mock"))
+	result, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "user", result)
+	}
+
+	_, err = client.Post("https://api.example.com/users?dry_run=true",
+		ghttp.WithJSON(ghttp.H{"name": "foo"}),
+	)
+	require.NoError(t, err)
+	if calls := post.Calls(); assert.Len(t, calls, 1) {
+		assert.Equal(t, `{"name":"foo"}`, string(calls[0].Body))
+	}
+
+	assert.True(t, mt.AssertExpectations(t))
+
+	// The first expectation only responds once
+	_, err = client.Get("https://api.example.com/users?page=1&size=10")
+	assert.True(t, errors.Is(err, ErrNoResponder))
+	assert.Len(t, mt.Calls(), 4)
+
+	rt := new(recordingT)
+	mt.On(ghttp.MethodDelete).Times(2)
+	assert.False(t, mt.AssertExpectations(rt))
+	assert.Len(t, rt.errors, 2)
+}
+
+func TestMockTransport_Retry(t *testing.T) {
+	mt := NewMockTransport()
+	e := mt.On(ghttp.MethodGet).Respond(Sequence(
+		NewErrorResponder(errors.New("connection reset")),
+		NewStringResponder(http.StatusTooManyRequests, "").Header("Retry-After", "0"),
+		NewStringResponder(http.StatusOK, "hello world").Gzip(),
+	))
+
+	client := ghttp.New()
+	client.Transport = mt
+	resp, err := client.Get("https://api.example.com",
+		ghttp.WithRetrier(
+			ghttp.WithRetryBackoff(ghttp.NewConstantBackoff(time.Millisecond, false)),
+		),
+	)
+	require.NoError(t, err)
+	result, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello world", result)
+	}
+	assert.Len(t, e.Calls(), 3)
+}
+
+func TestMockTransport_Concurrent(t *testing.T) {
+	mt := NewMockTransport()
+	e := mt.On(ghttp.MethodGet).Respond(NewJSONResponder(http.StatusOK, ghttp.H{"msg": "hello world"}))
+
+	client := ghttp.New()
+	client.Transport = mt
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get("https://api.example.com")
+			if !assert.NoError(t, err) {
+				return
+			}
+			// Encode something else to reuse the pooled buffers before reading the body
+			_, _ = gjson.Encode(ghttp.H{"msg": "overwritten"})
+			data, err := resp.H()
+			if assert.NoError(t, err) {
+				assert.Equal(t, "hello world", data.GetString("msg"))
+			}
+			_ = e.Calls()
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, e.Calls(), 8)
+}
+
+func TestResponder_Delay(t *testing.T) {
+	mt := NewMockTransport()
+	mt.On("").Respond(NewStringResponder(http.StatusOK, "").Delay(time.Second))
+
+	client := ghttp.New()
+	client.Transport = mt
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.Get("https://api.example.com", ghttp.WithContext(ctx))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestNewFileResponder(t *testing.T) {
+	file, err := ioutil.TempFile("", "ghttp-mock")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("hello world")
+	file.Close()
+
+	resp, err := NewFileResponder(http.StatusOK, file.Name())(nil)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello world", string(body))
+	}
+
+	_, err = NewFileResponder(http.StatusOK, file.Name()+".missing")(nil)
+	assert.Error(t, err)
+}
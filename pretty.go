@@ -0,0 +1,238 @@
+package ghttp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/winterssy/gjson"
+)
+
+// Color modes of the debugging output.
+const (
+	// ColorNever disables colorizing.
+	ColorNever ColorMode = iota
+
+	// ColorAuto colorizes the output if it's written to a terminal,
+	// unless the NO_COLOR environment variable is set.
+	ColorAuto
+
+	// ColorAlways colorizes the output unconditionally.
+	ColorAlways
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[1;31m"
+	ansiGreen  = "\x1b[1;32m"
+	ansiYellow = "\x1b[1;33m"
+	ansiBlue   = "\x1b[1;34m"
+	ansiCyan   = "\x1b[1;36m"
+
+	// The number of leading bytes shown in the hex dump of a binary body.
+	hexDumpSize = 64
+)
+
+type (
+	// ColorMode specifies when to colorize the debugging output.
+	ColorMode int
+
+	dumpFormat struct {
+		pretty bool
+		color  bool
+	}
+)
+
+// Report whether the debugging output should be colorized for w.
+func (cm ColorMode) enabled(w io.Writer) bool {
+	switch cm {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		_, noColor := os.LookupEnv("NO_COLOR")
+		return !noColor && isTerminal(w)
+	default:
+		return false
+	}
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (f dumpFormat) paint(s string, color string) string {
+	if !f.color {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+func (f dumpFormat) statusColor(statusCode int) string {
+	switch {
+	case statusCode >= http.StatusInternalServerError:
+		return ansiRed
+	case statusCode >= http.StatusBadRequest:
+		return ansiYellow
+	case statusCode >= http.StatusMultipleChoices:
+		return ansiCyan
+	default:
+		return ansiGreen
+	}
+}
+
+// Write body b to w, it's prettified according to contentType if enabled.
+func (f dumpFormat) writeBody(w io.Writer, contentType string, b []byte) {
+	if f.pretty {
+		b = prettyBody(contentType, b)
+	}
+	w.Write(b)
+	io.WriteString(w, "\r\n")
+}
+
+// Return the pretty form of body b, or b itself if it's not in a known format or malformed.
+func prettyBody(contentType string, b []byte) []byte {
+	if isBinary(b) {
+		return hexDumpSummary(b)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var pretty []byte
+	switch {
+	case isJSONContentType(contentType):
+		pretty = prettyJSON(b)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		pretty = prettyXML(b)
+	case mediaType == "application/x-www-form-urlencoded":
+		pretty = prettyForm(b)
+	}
+	if pretty == nil {
+		return b
+	}
+	return pretty
+}
+
+func isBinary(b []byte) bool {
+	return !utf8.Valid(b) || bytes.IndexByte(b, 0) >= 0
+}
+
+func hexDumpSummary(b []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "[binary data, %d bytes]\r\n", len(b))
+	if len(b) > hexDumpSize {
+		buf.WriteString(hex.Dump(b[:hexDumpSize]))
+		buf.WriteString("...")
+	} else {
+		buf.WriteString(strings.TrimSuffix(hex.Dump(b), "\n"))
+	}
+	return buf.Bytes()
+}
+
+func prettyJSON(b []byte) []byte {
+	var v interface{}
+	err := gjson.Decode(b, &v, func(dec *gjson.Decoder) {
+		dec.UseNumber()
+	})
+	if err != nil {
+		return nil
+	}
+
+	pretty, err := encodeJSON(v, func(enc *gjson.Encoder) {
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+	})
+	if err != nil {
+		return nil
+	}
+	return pretty
+}
+
+func prettyXML(b []byte) []byte {
+	var buf bytes.Buffer
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	for {
+		token, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil
+		}
+		// The whitespaces between elements are replaced with the indentation
+		if data, ok := token.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err = enc.EncodeToken(rawXMLToken(token)); err != nil {
+			return nil
+		}
+		// The encoder doesn't indent the root element after the declaration
+		if _, ok := token.(xml.ProcInst); ok {
+			enc.Flush()
+			buf.WriteByte('\n')
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// Keep the prefixes of the raw names as is, otherwise the encoder treats them as namespace URLs.
+func rawXMLToken(token xml.Token) xml.Token {
+	rawName := func(name xml.Name) xml.Name {
+		if name.Space != "" {
+			name.Local = name.Space + ":" + name.Local
+			name.Space = ""
+		}
+		return name
+	}
+
+	switch t := token.(type) {
+	case xml.StartElement:
+		t.Name = rawName(t.Name)
+		attrs := make([]xml.Attr, len(t.Attr))
+		for i, attr := range t.Attr {
+			attrs[i] = xml.Attr{Name: rawName(attr.Name), Value: attr.Value}
+		}
+		t.Attr = attrs
+		return t
+	case xml.EndElement:
+		t.Name = rawName(t.Name)
+		return t
+	default:
+		return token
+	}
+}
+
+func prettyForm(b []byte) []byte {
+	var buf bytes.Buffer
+	for i, pair := range strings.Split(string(b), "&") {
+		k, v := splitPair(pair, "=")
+		var err error
+		if k, err = neturl.QueryUnescape(k); err != nil {
+			return nil
+		}
+		if v, err = neturl.QueryUnescape(v); err != nil {
+			return nil
+		}
+		if i > 0 {
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString(k + ": " + v)
+	}
+	return buf.Bytes()
+}
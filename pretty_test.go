@@ -0,0 +1,74 @@
+package ghttp
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorMode_Enabled(t *testing.T) {
+	var sb strings.Builder
+	assert.False(t, ColorNever.enabled(&sb))
+	assert.True(t, ColorAlways.enabled(&sb))
+	assert.False(t, ColorAuto.enabled(&sb))
+
+	f, err := os.Open(os.DevNull)
+	if assert.NoError(t, err) {
+		defer f.Close()
+		// /dev/null is a character device as well, only NO_COLOR is checked here
+		os.Setenv("NO_COLOR", "1")
+		defer os.Unsetenv("NO_COLOR")
+		assert.False(t, ColorAuto.enabled(f))
+	}
+}
+
+func TestPrettyBody(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"<ghttp>","tags":["a",1.50]}`,
+			want:        "{\n  \"name\": \"<ghttp>\",\n  \"tags\": [\n    \"a\",\n    1.50\n  ]\n}",
+		},
+		{
+			contentType: "application/json",
+			body:        `{"name":`,
+			want:        `{"name":`,
+		},
+		{
+			contentType: "application/xml",
+			body:        `<?xml version="1.0"?><a:root xmlns:a="urn:a"><a:item id="1">ghttp</a:item>  <empty/></a:root>`,
+			want: "<?xml version=\"1.0\"?>\n<a:root xmlns:a=\"urn:a\">\n  <a:item id=\"1\">ghttp</a:item>\n" +
+				"  <empty></empty>\n</a:root>",
+		},
+		{
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=ghttp&q=hello+world%21&empty",
+			want:        "name: ghttp\r\nq: hello world!\r\nempty: ",
+		},
+		{
+			contentType: "text/plain",
+			body:        "hello world",
+			want:        "hello world",
+		},
+		{
+			contentType: "application/octet-stream",
+			body:        "\x00\x01ghttp",
+			want:        "[binary data, 7 bytes]\r\n00000000  00 01 67 68 74 74 70                              |..ghttp|",
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.want, string(prettyBody(testCase.contentType, []byte(testCase.body))))
+	}
+
+	b := bytes.Repeat([]byte{0xff}, hexDumpSize+1)
+	summary := string(prettyBody("image/png", b))
+	assert.True(t, strings.HasPrefix(summary, "[binary data, 65 bytes]\r\n"))
+	assert.True(t, strings.HasSuffix(summary, "|\n..."))
+}
@@ -12,22 +12,39 @@ type (
 	}
 )
 
-// Enter implements BeforeRequestCallback interface.
-func (rl *rateLimiter) Enter(req *Request) error {
-	return rl.base.Wait(req.Context())
+func (rl *rateLimiter) wrap(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		if err := rl.base.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		return next(req)
+	}
+}
+
+func (c *concurrency) wrap(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case c.ch <- struct{}{}:
+		}
+		defer c.release()
+
+		return next(req)
+	}
 }
 
-// Enter implements BeforeRequestCallback interface.
-func (c *concurrency) Enter(req *Request) error {
+// Try to take a slot without blocking and report whether it succeeds.
+func (c *concurrency) tryAcquire() bool {
 	select {
-	case <-req.Context().Done():
-		return req.Context().Err()
 	case c.ch <- struct{}{}:
-		return nil
+		return true
+	default:
+		return false
 	}
 }
 
-// Exit implements AfterResponseCallback interface.
-func (c *concurrency) Exit(*Response, error) {
+func (c *concurrency) release() {
 	<-c.ch
 }
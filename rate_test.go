@@ -10,7 +10,7 @@ import (
 	"golang.org/x/time/rate"
 )
 
-func TestRateLimiter_Enter(t *testing.T) {
+func TestRateLimiter_Wrap(t *testing.T) {
 	dummyRequest := &Request{Request: &http.Request{
 		URL: &neturl.URL{
 			Scheme: "https",
@@ -21,17 +21,20 @@ func TestRateLimiter_Enter(t *testing.T) {
 	}}
 
 	rl := &rateLimiter{base: rate.NewLimiter(1, 10)}
-	err := rl.Enter(dummyRequest)
+	handler := rl.wrap(func(req *Request) (*Response, error) {
+		return &Response{}, nil
+	})
+	_, err := handler(dummyRequest)
 	assert.NoError(t, err)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	dummyRequest.SetContext(ctx)
 	cancel()
-	err = rl.Enter(dummyRequest)
+	_, err = handler(dummyRequest)
 	assert.Equal(t, ctx.Err(), err)
 }
 
-func TestConcurrency_Enter(t *testing.T) {
+func TestConcurrency_Wrap(t *testing.T) {
 	dummyRequest := &Request{Request: &http.Request{
 		URL: &neturl.URL{
 			Scheme: "https",
@@ -42,12 +45,19 @@ func TestConcurrency_Enter(t *testing.T) {
 	}}
 
 	c := &concurrency{ch: make(chan struct{}, 1)}
-	err := c.Enter(dummyRequest)
-	assert.NoError(t, err)
-
+	handler := c.wrap(func(req *Request) (*Response, error) {
+		assert.Len(t, c.ch, 1)
+		return &Response{}, nil
+	})
+	_, err := handler(dummyRequest)
+	if assert.NoError(t, err) {
+		assert.Len(t, c.ch, 0)
+	}
+
+	c.ch <- struct{}{}
 	ctx, cancel := context.WithCancel(context.Background())
 	dummyRequest.SetContext(ctx)
 	cancel()
-	err = c.Enter(dummyRequest)
+	_, err = handler(dummyRequest)
 	assert.Equal(t, ctx.Err(), err)
 }
@@ -0,0 +1,240 @@
+package ghttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/winterssy/gjson"
+)
+
+type (
+	// RedactOption configures the redaction of the debugging output and the dumps,
+	// see WithDebugRedaction, Request.Dump and Response.Dump.
+	RedactOption func(r *redactor)
+
+	redactor struct {
+		redactHeaders map[string]bool
+		allowHeaders  map[string]bool
+		redactQuery   map[string]bool
+		jsonFields    [][]string
+		maxBodySize   int64
+	}
+)
+
+func newRedactor(opts []RedactOption) *redactor {
+	r := &redactor{
+		redactHeaders: make(map[string]bool),
+		redactQuery:   make(map[string]bool),
+	}
+	for _, key := range defaultRedactHeaders {
+		r.redactHeaders[key] = true
+	}
+	for _, key := range defaultRedactQueryParams {
+		r.redactQuery[key] = true
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithRedactHeaders is a redact option that specifies more headers to mask, i.e. a denylist.
+// The Authorization, Proxy-Authorization, Cookie and Set-Cookie headers are always masked.
+func WithRedactHeaders(keys ...string) RedactOption {
+	return func(r *redactor) {
+		for _, key := range keys {
+			r.redactHeaders[http.CanonicalHeaderKey(key)] = true
+		}
+	}
+}
+
+// WithAllowHeaders is a redact option that specifies the headers to show, i.e. an allowlist,
+// the values of all other headers are masked.
+// The headers in the denylist are masked even if allowed.
+func WithAllowHeaders(keys ...string) RedactOption {
+	return func(r *redactor) {
+		if r.allowHeaders == nil {
+			r.allowHeaders = make(map[string]bool, len(keys))
+		}
+		for _, key := range keys {
+			r.allowHeaders[http.CanonicalHeaderKey(key)] = true
+		}
+	}
+}
+
+// WithRedactQuery is a redact option that specifies more query params to mask,
+// compared case-insensitively.
+// The common sensitive params such as token, api_key and password are always masked.
+func WithRedactQuery(keys ...string) RedactOption {
+	return func(r *redactor) {
+		for _, key := range keys {
+			r.redactQuery[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// WithRedactJSONFields is a redact option that specifies the fields to mask in JSON bodies.
+// A path is a sequence of keys separated by dots, e.g. "user.password",
+// an array element is addressed by its index or by # for all elements, e.g. "users.#.token".
+func WithRedactJSONFields(paths ...string) RedactOption {
+	return func(r *redactor) {
+		for _, path := range paths {
+			r.jsonFields = append(r.jsonFields, strings.Split(path, "."))
+		}
+	}
+}
+
+// WithMaxBodySize is a redact option that truncates the bodies to maxSize bytes,
+// while maxSize less than or equal to zero means no limit.
+// By default is no limit.
+func WithMaxBodySize(maxSize int64) RedactOption {
+	return func(r *redactor) {
+		r.maxBodySize = maxSize
+	}
+}
+
+func (r *redactor) headerMasked(key string) bool {
+	return r.redactHeaders[key] || r.allowHeaders != nil && !r.allowHeaders[key]
+}
+
+func (r *redactor) redactHeader(h http.Header) http.Header {
+	return maskHeader(h, r.headerMasked)
+}
+
+func (r *redactor) redactBody(contentType string, b []byte) []byte {
+	if len(r.jsonFields) > 0 && isJSONContentType(contentType) {
+		b = redactJSON(b, r.jsonFields)
+	}
+	if b, truncated := truncateBody(b, r.maxBodySize); truncated {
+		return append(b[:len(b):len(b)], "...(truncated)"...)
+	}
+	return b
+}
+
+// Return a copy of req with the secrets masked.
+// If body is true, the body of req is buffered so that it's still readable.
+func (r *redactor) redactRequest(req *Request, body bool) (*Request, error) {
+	redacted := *req
+	redacted.Request = req.Request.Clone(req.Context())
+	redacted.Header = r.redactHeader(req.Header)
+	redacted.URL = maskQuery(req.URL, r.redactQuery)
+	if !body || bodyEmpty(req.Body) {
+		return &redacted, nil
+	}
+
+	b, err := req.peekBody()
+	if err != nil {
+		return nil, err
+	}
+	redacted.SetContent(r.redactBody(req.Header.Get("Content-Type"), b))
+	redacted.formData = req.formData
+	return &redacted, nil
+}
+
+// Return a copy of resp with the secrets masked.
+// If body is true, the body of resp is read and replaced so that it's still readable.
+func (r *redactor) redactResponse(resp *Response, body bool) (*Response, error) {
+	raw := *resp.Response
+	raw.Header = r.redactHeader(resp.Header)
+	redacted := *resp
+	redacted.Response = &raw
+	if !body || bodyEmpty(resp.Body) {
+		return &redacted, nil
+	}
+
+	b, err := resp.peekBody(0)
+	if err != nil {
+		return nil, err
+	}
+	b = r.redactBody(resp.Header.Get("Content-Type"), b)
+	raw.Body = ioutil.NopCloser(bytes.NewReader(b))
+	raw.ContentLength = int64(len(b))
+	return &redacted, nil
+}
+
+// Return a copy of u with the values of the specified query params masked,
+// or u itself if there's nothing to mask.
+func maskQuery(u *neturl.URL, keys map[string]bool) *neturl.URL {
+	if u == nil || u.RawQuery == "" {
+		return u
+	}
+
+	query := u.Query()
+	masked := false
+	for k, vs := range query {
+		if keys[strings.ToLower(k)] {
+			for i := range vs {
+				vs[i] = RedactedValue
+			}
+			masked = true
+		}
+	}
+	if !masked {
+		return u
+	}
+
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return &redacted
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Mask the fields at paths in the JSON document b, b is returned as is if it's not valid JSON.
+func redactJSON(b []byte, paths [][]string) []byte {
+	var v interface{}
+	err := gjson.Decode(b, &v, func(dec *gjson.Decoder) {
+		dec.UseNumber()
+	})
+	if err != nil {
+		return b
+	}
+
+	for _, path := range paths {
+		redactJSONPath(v, path)
+	}
+	redacted, err := encodeJSON(v, func(enc *gjson.Encoder) {
+		enc.SetEscapeHTML(false)
+	})
+	if err != nil {
+		return b
+	}
+	return redacted
+}
+
+func redactJSONPath(v interface{}, path []string) {
+	key, last := path[0], len(path) == 1
+	switch v := v.(type) {
+	case map[string]interface{}:
+		child, ok := v[key]
+		switch {
+		case !ok:
+		case last:
+			v[key] = RedactedValue
+		default:
+			redactJSONPath(child, path[1:])
+		}
+	case []interface{}:
+		for i := range v {
+			if key != "#" && key != strconv.Itoa(i) {
+				continue
+			}
+			if last {
+				v[i] = RedactedValue
+			} else {
+				redactJSONPath(v[i], path[1:])
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ghttp
+
+import (
+	"net/http"
+	neturl "net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor_RedactHeader(t *testing.T) {
+	h := http.Header{
+		"Authorization": {"Bearer secret"},
+		"Accept":        {"application/json"},
+		"X-Api-Key":     {"key"},
+		"X-Request-Id":  {"1"},
+	}
+
+	r := newRedactor([]RedactOption{WithRedactHeaders("x-api-key")})
+	redacted := r.redactHeader(h)
+	assert.Equal(t, RedactedValue, redacted.Get("Authorization"))
+	assert.Equal(t, RedactedValue, redacted.Get("X-Api-Key"))
+	assert.Equal(t, "application/json", redacted.Get("Accept"))
+	assert.Equal(t, "Bearer secret", h.Get("Authorization"))
+
+	r = newRedactor([]RedactOption{WithAllowHeaders("Accept", "Authorization")})
+	redacted = r.redactHeader(h)
+	assert.Equal(t, RedactedValue, redacted.Get("Authorization"))
+	assert.Equal(t, RedactedValue, redacted.Get("X-Request-Id"))
+	assert.Equal(t, "application/json", redacted.Get("Accept"))
+}
+
+func TestMaskQuery(t *testing.T) {
+	keys := map[string]bool{"token": true}
+
+	u, _ := neturl.Parse("https://httpbin.org/get?b=1&a=2")
+	assert.Same(t, u, maskQuery(u, keys))
+
+	u, _ = neturl.Parse("https://httpbin.org/get?Token=abc&page=1")
+	assert.Equal(t, "https://httpbin.org/get?Token=REDACTED&page=1", maskQuery(u, keys).String())
+	assert.Equal(t, "Token=abc&page=1", u.RawQuery)
+}
+
+func TestRedactor_RedactBody(t *testing.T) {
+	r := newRedactor([]RedactOption{WithRedactJSONFields("password", "user.token", "items.#.secret", "tags.1", "missing.key")})
+
+	body := []byte(`{"password":"p","user":{"name":"<ghttp>","token":"t"},"items":[{"secret":1},{"secret":2.50}],"tags":["a","b"]}`)
+	want := `{"items":[{"secret":"REDACTED"},{"secret":"REDACTED"}],"password":"REDACTED",` +
+		`"tags":["a","REDACTED"],"user":{"name":"<ghttp>","token":"REDACTED"}}`
+	assert.Equal(t, want, string(r.redactBody("application/json; charset=utf-8", body)))
+	assert.Equal(t, want, string(r.redactBody("application/problem+json", body)))
+	assert.Equal(t, string(body), string(r.redactBody("text/plain", body)))
+	assert.Equal(t, "not json", string(r.redactBody("application/json", []byte("not json"))))
+
+	r = newRedactor([]RedactOption{WithMaxBodySize(5)})
+	assert.Equal(t, "hello...(truncated)", string(r.redactBody("text/plain", []byte("hello world"))))
+	assert.Equal(t, "hello", string(r.redactBody("text/plain", []byte("hello"))))
+}
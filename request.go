@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strings"
+	"time"
 
 	"github.com/winterssy/gjson"
 )
@@ -29,8 +30,14 @@ type (
 	// Request is a wrapper around an http.Request.
 	Request struct {
 		*http.Request
-		retrier     *retrier
-		clientTrace bool
+		retrier            *retrier
+		clientTrace        bool
+		middlewares        []Middleware
+		attemptMiddlewares []Middleware
+		attemptNum         int
+		hedger             *hedger
+		formData           *FormData
+		sseReconnect       *sseReconnect
 	}
 
 	// RequestHook is a function that implements BeforeRequestCallback interface.
@@ -113,6 +120,7 @@ func (req *Request) AddCookies(cookies Cookies) {
 // SetBody sets body for req.
 func (req *Request) SetBody(body io.Reader) {
 	req.Body = toReadCloser(body)
+	req.formData, _ = body.(*FormData)
 	if body != nil {
 		switch v := body.(type) {
 		case *bytes.Buffer:
@@ -177,7 +185,7 @@ func (req *Request) SetForm(form Form) {
 
 // SetJSON sets JSON payload for req.
 func (req *Request) SetJSON(data interface{}, opts ...func(enc *gjson.Encoder)) error {
-	b, err := gjson.Encode(data, opts...)
+	b, err := encodeJSON(data, opts...)
 	if err != nil {
 		return err
 	}
@@ -194,6 +202,32 @@ func (req *Request) SetFiles(files Files) {
 	req.SetContentType(formData.ContentType())
 }
 
+// Make req's body replayable by reading it into memory, so that req can be sent more than once.
+func (req *Request) bufferBody() (err error) {
+	if req.Body != nil && req.GetBody == nil {
+		// Don't use a pooled buffer since the content is kept by req
+		var b []byte
+		b, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err == nil {
+			req.SetContent(b)
+		}
+	}
+	return
+}
+
+// Return a copy of req's body, the body is buffered so that it's still readable.
+func (req *Request) peekBody() ([]byte, error) {
+	if err := req.bufferBody(); err != nil {
+		return nil, err
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(body)
+}
+
 // SetContext sets context for req.
 func (req *Request) SetContext(ctx context.Context) {
 	req.Request = req.WithContext(ctx)
@@ -208,14 +242,54 @@ func (req *Request) EnableRetrier(opts ...RetryOption) {
 	req.retrier = retrier
 }
 
+// EnableHedging enables hedging for req, which sends another copy of req if no response is received
+// within delay, up to maxHedges extra copies, and returns the first successful response.
+// A response is considered successful if its status code isn't 5xx.
+// The other copies are cancelled once a winner emerges.
+// Hedging only works for idempotent requests, or those carry an Idempotency-Key header.
+func (req *Request) EnableHedging(delay time.Duration, maxHedges int) {
+	req.hedger = &hedger{
+		delay:     delay,
+		maxHedges: maxHedges,
+	}
+}
+
+// Use appends req's middlewares.
+// They're invoked after the Client's, see Client.Use for more details.
+func (req *Request) Use(middlewares ...Middleware) {
+	req.middlewares = append(req.middlewares, middlewares...)
+}
+
+// UseAttempt appends req's attempt middlewares.
+// They're invoked after the Client's, see Client.UseAttempt for more details.
+func (req *Request) UseAttempt(middlewares ...Middleware) {
+	req.attemptMiddlewares = append(req.attemptMiddlewares, middlewares...)
+}
+
+// AttemptNum returns the zero-based number of the current attempt of req.
+// It's meaningful in the attempt middlewares only, see Client.UseAttempt.
+func (req *Request) AttemptNum() int {
+	return req.attemptNum
+}
+
 // WithClientTrace enables client trace for req using httptrace.ClientTrace.
 func (req *Request) EnableClientTrace() {
 	req.clientTrace = true
 }
 
 // Dump returns the HTTP/1.x wire representation of req.
-func (req *Request) Dump(withBody bool) ([]byte, error) {
-	return httputil.DumpRequestOut(req.Request, withBody)
+// If any redact option is specified, the secrets are masked in the dump and the body is
+// still readable after dumped, see RedactOption for more details.
+func (req *Request) Dump(withBody bool, opts ...RedactOption) ([]byte, error) {
+	if len(opts) == 0 {
+		return httputil.DumpRequestOut(req.Request, withBody)
+	}
+
+	redacted, err := newRedactor(opts).redactRequest(req, withBody)
+	if err != nil {
+		return nil, err
+	}
+	return httputil.DumpRequestOut(redacted.Request, withBody)
 }
 
 // WithQuery is a request hook to set query parameters.
@@ -355,6 +429,14 @@ func WithRetrier(opts ...RetryOption) RequestHook {
 	}
 }
 
+// WithHedging is a request hook to enable hedging.
+func WithHedging(delay time.Duration, maxHedges int) RequestHook {
+	return func(req *Request) error {
+		req.EnableHedging(delay, maxHedges)
+		return nil
+	}
+}
+
 // WithClientTrace is a request hook to enable client trace.
 func WithClientTrace() RequestHook {
 	return func(req *Request) error {
@@ -362,3 +444,19 @@ func WithClientTrace() RequestHook {
 		return nil
 	}
 }
+
+// WithMiddlewares is a request hook to append middlewares.
+func WithMiddlewares(middlewares ...Middleware) RequestHook {
+	return func(req *Request) error {
+		req.Use(middlewares...)
+		return nil
+	}
+}
+
+// WithAttemptMiddlewares is a request hook to append attempt middlewares.
+func WithAttemptMiddlewares(middlewares ...Middleware) RequestHook {
+	return func(req *Request) error {
+		req.UseAttempt(middlewares...)
+		return nil
+	}
+}
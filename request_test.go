@@ -14,6 +14,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/winterssy/bufferpool"
 )
 
 func TestNewRequest(t *testing.T) {
@@ -279,7 +280,7 @@ func TestRequest_EnableRetrier(t *testing.T) {
 
 	client := New()
 	resp, err := client.
-		Post(ts.URL,
+		Put(ts.URL,
 			WithText(dummyData),
 			WithRetrier(),
 		)
@@ -288,6 +289,19 @@ func TestRequest_EnableRetrier(t *testing.T) {
 		assert.Equal(t, defaultRetryMaxAttempts, attempts-1)
 	}
 
+	// Non-idempotent methods aren't retried by default
+	attempts = 0
+	resp, err = client.
+		Post(ts.URL,
+			WithText(dummyData),
+			WithRetrier(),
+		)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, 1, attempts)
+	}
+	attempts = 0
+
 	resp, err = client.
 		Get(ts.URL,
 			WithRetrier(
@@ -309,6 +323,30 @@ func TestRequest_EnableRetrier(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRequest_BufferBody(t *testing.T) {
+	req, err := NewRequest(MethodPost, "https://httpbin.org/post")
+	require.NoError(t, err)
+	req.Body = ioutil.NopCloser(strings.NewReader("hello world"))
+	require.NoError(t, req.bufferBody())
+
+	// Reuse the pooled buffers, which must not be shared with the buffered body
+	for i := 0; i < 8; i++ {
+		buf := bufferpool.Get()
+		buf.WriteString("overwritten")
+		buf.Free()
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+
+	rc, err := req.GetBody()
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body))
+}
+
 func TestRequest_EnableClientTrace(t *testing.T) {
 	dummyRequest := &Request{}
 	assert.False(t, dummyRequest.clientTrace)
@@ -324,3 +362,31 @@ func TestRequest_Dump(t *testing.T) {
 		assert.NoError(t, err)
 	}
 }
+
+func TestRequest_DumpWithRedaction(t *testing.T) {
+	req, _ := NewRequest(MethodPost, "https://httpbin.org/post?api_key=abc")
+	req.SetBasicAuth("user", "pass")
+	_ = req.SetJSON(map[string]string{"password": "secret"})
+
+	dump, err := req.Dump(true, WithRedactJSONFields("password"))
+	require.NoError(t, err)
+	s := string(dump)
+	assert.Contains(t, s, "POST /post?api_key=REDACTED HTTP/1.1\r\n")
+	assert.Contains(t, s, "Authorization: REDACTED\r\n")
+	assert.Contains(t, s, "Content-Length: 23\r\n")
+	assert.True(t, strings.HasSuffix(s, `{"password":"REDACTED"}`))
+
+	// The body is still readable after dumped
+	b, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"password":"secret"}`, string(b))
+
+	// The body to send isn't shared with the redacted copy
+	_, err = req.Dump(true, WithRedactJSONFields("password"))
+	require.NoError(t, err)
+	rc, err := req.GetBody()
+	require.NoError(t, err)
+	b, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, `{"password":"secret"}`, string(b))
+}
@@ -1,6 +1,9 @@
 package ghttp
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
 	"os"
@@ -15,6 +18,9 @@ type (
 	Response struct {
 		*http.Response
 		clientTrace *clientTrace
+		traces      []*attemptTrace
+		cacheStatus CacheStatus
+		attempts    []Attempt
 	}
 )
 
@@ -29,6 +35,7 @@ func (resp *Response) Content() ([]byte, error) {
 	buf := bufferpool.Get()
 	defer buf.Free()
 	err := drainBody(resp.Body, buf)
+	resp.bodyRead()
 	return buf.Bytes(), err
 }
 
@@ -46,6 +53,7 @@ func (resp *Response) Text(e ...encoding.Encoding) (string, error) {
 // JSON decodes resp's body and unmarshals its JSON-encoded data into v.
 // v must be a pointer.
 func (resp *Response) JSON(v interface{}, opts ...func(dec *gjson.Decoder)) error {
+	defer resp.bodyRead()
 	defer resp.Body.Close()
 	return gjson.NewDecoder(resp.Body, opts...).Decode(v)
 }
@@ -64,6 +72,7 @@ func (resp *Response) SaveFile(filename string, perm os.FileMode) (err error) {
 	if err == nil {
 		defer file.Close()
 		err = drainBody(resp.Body, file)
+		resp.bodyRead()
 	}
 	return
 }
@@ -72,11 +81,67 @@ func (resp *Response) SaveFile(filename string, perm os.FileMode) (err error) {
 func (resp *Response) TraceInfo() (traceInfo *TraceInfo) {
 	if resp.clientTrace != nil {
 		traceInfo = resp.clientTrace.traceInfo()
+		if resp.Response != nil {
+			traceInfo.Protocol = resp.Proto
+		}
 	}
 	return
 }
 
+// TraceHistory returns the trace info of all attempts and redirects for the request if client trace
+// is enabled, while TraceInfo only describes the last one.
+func (resp *Response) TraceHistory() *TraceHistory {
+	if len(resp.traces) == 0 {
+		return nil
+	}
+	return newTraceHistory(resp.traces, resp.attempts)
+}
+
+// Record the time that resp's body is read completely for the trace info.
+func (resp *Response) bodyRead() {
+	if resp.clientTrace != nil {
+		resp.clientTrace.readBody()
+	}
+}
+
+// Read the first maxSize+1 bytes of resp's body, or the whole body if maxSize less than or equal
+// to zero, and put them back so that the body is still readable from the start.
+func (resp *Response) peekBody(maxSize int64) ([]byte, error) {
+	var reader io.Reader = resp.Body
+	if maxSize > 0 {
+		reader = io.LimitReader(resp.Body, maxSize+1)
+	}
+	b, err := ioutil.ReadAll(reader)
+	resp.Body = &multiReadCloser{
+		Reader: io.MultiReader(bytes.NewReader(b), resp.Body),
+		Closer: resp.Body,
+	}
+	return b, err
+}
+
+// CacheStatus reports whether resp was served from cache, revalidated or fetched from the origin server.
+// It's meaningful only if caching is enabled, otherwise it's always CacheMiss.
+func (resp *Response) CacheStatus() CacheStatus {
+	return resp.cacheStatus
+}
+
+// Attempts returns the attempts made for the request if retrier is enabled.
+// When the request fails, the attempts are also available from the returned *RetryError.
+func (resp *Response) Attempts() []Attempt {
+	return resp.attempts
+}
+
 // Dump returns the HTTP/1.x wire representation of resp.
-func (resp *Response) Dump(body bool) ([]byte, error) {
-	return httputil.DumpResponse(resp.Response, body)
+// If any redact option is specified, the secrets are masked in the dump,
+// see RedactOption for more details.
+func (resp *Response) Dump(body bool, opts ...RedactOption) ([]byte, error) {
+	if len(opts) == 0 {
+		return httputil.DumpResponse(resp.Response, body)
+	}
+
+	redacted, err := newRedactor(opts).redactResponse(resp, body)
+	if err != nil {
+		return nil, err
+	}
+	return httputil.DumpResponse(redacted.Response, body)
 }
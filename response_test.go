@@ -2,9 +2,13 @@ package ghttp
 
 import (
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -133,3 +137,193 @@ func TestResponse_Dump(t *testing.T) {
 	_, err = resp.Dump(true)
 	assert.NoError(t, err)
 }
+
+func TestResponse_Attempts(t *testing.T) {
+	const backoff = 10 * time.Millisecond
+
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint64(&counter, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp, err := client.Get(ts.URL)
+	if assert.NoError(t, err) {
+		assert.Empty(t, resp.Attempts())
+	}
+
+	atomic.StoreUint64(&counter, 0)
+	resp, err = client.Get(ts.URL,
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(backoff, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode != http.StatusOK
+			}),
+		),
+	)
+	if assert.NoError(t, err) {
+		attempts := resp.Attempts()
+		if assert.Len(t, attempts, 3) {
+			assert.Equal(t, http.StatusServiceUnavailable, attempts[0].StatusCode)
+			assert.Equal(t, http.StatusServiceUnavailable, attempts[1].StatusCode)
+			assert.Equal(t, http.StatusOK, attempts[2].StatusCode)
+			assert.True(t, attempts[0].Duration > 0)
+			assert.True(t, attempts[0].Backoff >= backoff)
+			assert.True(t, attempts[1].Backoff >= backoff)
+			assert.Zero(t, attempts[2].Backoff)
+		}
+	}
+}
+
+func TestResponse_DumpWithRedaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Api-Key", "key")
+		w.Write([]byte("hello world"))
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+
+	dump, err := resp.Dump(true, WithRedactHeaders("X-Api-Key"), WithMaxBodySize(5))
+	require.NoError(t, err)
+	s := string(dump)
+	assert.Contains(t, s, "X-Api-Key: REDACTED\r\n")
+	assert.True(t, strings.HasSuffix(s, "\r\n\r\nhello...(truncated)"))
+
+	text, err := resp.Text()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", text)
+}
+
+func TestResponse_TraceInfoDetails(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("hello world"))
+	}))
+	defer ts.Close()
+
+	client := New()
+	client.DisableTLSVerify()
+	url := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	resp, err := client.Post(url, WithText("ghttp"), WithClientTrace())
+	require.NoError(t, err)
+
+	traceInfo := resp.TraceInfo()
+	require.NotNil(t, traceInfo)
+	assert.Zero(t, traceInfo.ResponseBodyTime)
+	assert.Contains(t, traceInfo.ResolvedIPs, "127.0.0.1")
+	assert.Equal(t, ts.Listener.Addr().String(), traceInfo.RemoteAddr)
+	assert.NotEmpty(t, traceInfo.LocalAddr)
+	assert.Equal(t, "HTTP/1.1", traceInfo.Protocol)
+	assert.True(t, strings.HasPrefix(traceInfo.TLSVersion, "TLS 1."))
+	assert.True(t, strings.HasPrefix(traceInfo.TLSCipherSuite, "TLS_"))
+	assert.False(t, traceInfo.TLSResumed)
+	require.NotEmpty(t, traceInfo.PeerCertificates)
+	assert.Equal(t, "O=Acme Co", traceInfo.PeerCertificates[0].Subject)
+	assert.True(t, traceInfo.RequestBodyTime >= 0)
+
+	_, err = resp.Content()
+	require.NoError(t, err)
+	assert.True(t, resp.TraceInfo().ResponseBodyTime >= 20*time.Millisecond)
+}
+
+func TestResponse_TraceHistory(t *testing.T) {
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/final":
+			if atomic.AddUint64(&counter, 1) < 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp, err := client.Get(ts.URL+"/start",
+		WithClientTrace(),
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(10*time.Millisecond, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode != http.StatusOK
+			}),
+		),
+	)
+	require.NoError(t, err)
+
+	history := resp.TraceHistory()
+	require.NotNil(t, history)
+	require.Len(t, history.Entries, 4)
+	want := []struct {
+		attempt    int
+		redirect   int
+		path       string
+		statusCode int
+	}{
+		{1, 0, "/start", http.StatusFound},
+		{1, 1, "/final", http.StatusServiceUnavailable},
+		{2, 0, "/start", http.StatusFound},
+		{2, 1, "/final", http.StatusOK},
+	}
+	for i, entry := range history.Entries {
+		assert.Equal(t, want[i].attempt, entry.Attempt)
+		assert.Equal(t, want[i].redirect, entry.Redirect)
+		assert.Equal(t, MethodGet, entry.Method)
+		assert.Equal(t, ts.URL+want[i].path, entry.URL)
+		assert.Equal(t, want[i].statusCode, entry.StatusCode)
+		assert.NoError(t, entry.Err)
+		assert.True(t, entry.TotalTime > 0)
+		assert.True(t, entry.ServerTime >= 0)
+	}
+	assert.False(t, history.Entries[0].ConnReused)
+	assert.True(t, history.Entries[1].ConnReused)
+
+	total := history.Total
+	assert.Equal(t, 2, total.Attempts)
+	assert.Equal(t, 2, total.Redirects)
+	assert.Equal(t, 3, total.ConnReused)
+	assert.True(t, total.BackoffTime >= 10*time.Millisecond)
+	assert.True(t, total.TotalTime >= total.BackoffTime+history.Entries[0].TotalTime)
+
+	// TraceInfo still describes the last one
+	assert.True(t, resp.TraceInfo().ConnReused)
+
+	resp, err = client.Get("http://127.0.0.1:0/get", WithClientTrace())
+	require.Error(t, err)
+	history = resp.TraceHistory()
+	require.Len(t, history.Entries, 1)
+	assert.Equal(t, "http://127.0.0.1:0/get", history.Entries[0].URL)
+	assert.Error(t, history.Entries[0].Err)
+
+	resp, err = client.Get(ts.URL + "/final")
+	require.NoError(t, err)
+	assert.Nil(t, resp.TraceHistory())
+}
+
+func TestResponse_PeekBody(t *testing.T) {
+	resp := &Response{
+		Response: &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader("hello world")),
+		},
+	}
+	b, err := resp.peekBody(5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello ", string(b))
+
+	b, err = resp.peekBody(0)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+
+	text, err := resp.Text()
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", text)
+}
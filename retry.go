@@ -2,25 +2,87 @@ package ghttp
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptrace"
+	neturl "net/url"
+	"strings"
+	"sync/atomic"
 	"time"
+)
 
-	"github.com/winterssy/bufferpool"
+const (
+	defaultRetryMaxAttempts   = 3
+	defaultRetryAfterMaxDelay = 5 * time.Minute
 )
 
 const (
-	defaultRetryMaxAttempts = 3
+	// IdempotencyKeyHeader is the header used to make a non-idempotent request safe to retry,
+	// see the IETF draft "The Idempotency-Key HTTP Header Field".
+	IdempotencyKeyHeader = "Idempotency-Key"
 )
 
 var (
-	defaultRetryBackoff = NewExponentialBackoff(1*time.Second, 30*time.Second, true)
+	// ErrAttemptTimeout is reported when an attempt exceeds the per-attempt timeout of a retrier.
+	ErrAttemptTimeout error = &attemptTimeoutError{}
+
+	defaultRetryMethods = []string{
+		MethodGet,
+		MethodHead,
+		MethodOptions,
+		MethodPut,
+		MethodDelete,
+		MethodTrace,
+	}
+
+	defaultRetryBackoff = NewRetryAfterBackoff(
+		NewExponentialBackoff(1*time.Second, 30*time.Second, true),
+		defaultRetryAfterMaxDelay,
+	)
 )
 
 type (
 	retrier struct {
-		maxAttempts int
-		backoff     Backoff
-		triggers    []func(resp *Response, err error) bool
+		maxAttempts       int
+		backoff           Backoff
+		triggers          []func(resp *Response, err error) bool
+		methods           map[string]bool
+		idempotencyKey    func() string
+		perAttemptTimeout time.Duration
+	}
+
+	attemptTimeoutError struct{}
+
+	// Attempt records the outcome of an attempt made by a retrier.
+	Attempt struct {
+		// StatusCode is the response status code of the attempt, or 0 if no response received.
+		StatusCode int `json:"status_code,omitempty"`
+
+		// Err is the error occurred in the attempt, if any.
+		Err error `json:"-"`
+
+		// Duration is the time the attempt took until the response headers received.
+		Duration time.Duration `json:"duration"`
+
+		// Backoff is the time slept after the attempt before the next one,
+		// 0 if it's the last attempt.
+		Backoff time.Duration `json:"backoff"`
+	}
+
+	// RetryError is returned when a request with retrier enabled fails,
+	// it records all attempts made.
+	RetryError struct {
+		// Attempts are the attempts made in order.
+		Attempts []Attempt
+
+		// Err is the error that ends the request, typically it's the error of the last attempt.
+		Err error
+	}
+
+	// Track whether a request is written to the wire completely.
+	writeTracker struct {
+		written int32
 	}
 
 	// RetryOption configures a retrier.
@@ -28,20 +90,21 @@ type (
 )
 
 func defaultRetrier() *retrier {
-	return &retrier{
+	r := &retrier{
 		maxAttempts: defaultRetryMaxAttempts,
 		backoff:     defaultRetryBackoff,
 	}
+	WithRetryMethods(defaultRetryMethods...)(r)
+	return r
 }
 
 func (r *retrier) modifyRequest(req *Request) (err error) {
-	if r.maxAttempts > 0 && req.Body != nil && req.GetBody == nil {
-		buf := bufferpool.Get()
-		defer buf.Free()
-		err = drainBody(req.Body, buf)
-		if err == nil {
-			req.SetContent(buf.Bytes())
-		}
+	if r.idempotencyKey != nil && !r.methods[req.Method] && req.Header.Get(IdempotencyKeyHeader) == "" {
+		req.Header.Set(IdempotencyKeyHeader, r.idempotencyKey())
+	}
+
+	if r.maxAttempts > 0 {
+		err = req.bufferBody()
 	}
 	return
 }
@@ -52,8 +115,18 @@ func (r *retrier) on(ctx context.Context, attemptNum int, resp *Response, err er
 		return false
 	}
 
+	return r.failed(resp, err)
+}
+
+// Report whether an attempt fails according to the retry triggers.
+// An attempt that exceeds the per-attempt timeout is always considered as failed.
+func (r *retrier) failed(resp *Response, err error) bool {
+	if errors.Is(err, ErrAttemptTimeout) {
+		return true
+	}
+
 	if len(r.triggers) == 0 {
-		return err != nil || resp.StatusCode == http.StatusTooManyRequests
+		return attemptFailed(resp, err)
 	}
 
 	for _, trigger := range r.triggers {
@@ -65,6 +138,135 @@ func (r *retrier) on(ctx context.Context, attemptNum int, resp *Response, err er
 	return false
 }
 
+// Report whether an attempt fails by default, that is, it gets an error or a 429 response.
+func attemptFailed(resp *Response, err error) bool {
+	return err != nil || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// Report whether a request is safe to retry.
+// A request is safe to retry if its method is allowed to retry, it carries an idempotency key,
+// or it failed before being written to the wire, which means the server never received it.
+func (r *retrier) safe(req *Request, err error, written bool) bool {
+	if r.methods[req.Method] || req.Header.Get(IdempotencyKeyHeader) != "" {
+		return true
+	}
+
+	return err != nil && !written
+}
+
+func (wt *writeTracker) modifyRequest(req *Request) {
+	ctx := httptrace.WithClientTrace(
+		req.Context(),
+		&httptrace.ClientTrace{
+			WroteRequest: func(info httptrace.WroteRequestInfo) {
+				if info.Err == nil {
+					atomic.StoreInt32(&wt.written, 1)
+				}
+			},
+		},
+	)
+	req.Request = req.WithContext(ctx)
+}
+
+func (wt *writeTracker) reset() {
+	atomic.StoreInt32(&wt.written, 0)
+}
+
+func (wt *writeTracker) done() bool {
+	return atomic.LoadInt32(&wt.written) == 1
+}
+
+// Error implements error interface.
+func (*attemptTimeoutError) Error() string {
+	return "ghttp: attempt timeout exceeded"
+}
+
+// Timeout implements net.Error interface.
+func (*attemptTimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary implements net.Error interface.
+func (*attemptTimeoutError) Temporary() bool {
+	return true
+}
+
+// Replace the cause of err with ErrAttemptTimeout.
+func attemptTimeout(err error) error {
+	if ue, ok := err.(*neturl.Error); ok {
+		return &neturl.Error{Op: ue.Op, URL: ue.URL, Err: ErrAttemptTimeout}
+	}
+	return ErrAttemptTimeout
+}
+
+func newAttempt(resp *Response, err error, duration time.Duration) Attempt {
+	attempt := Attempt{Err: err, Duration: duration}
+	if resp != nil && resp.Response != nil {
+		attempt.StatusCode = resp.StatusCode
+	}
+	return attempt
+}
+
+// String implements fmt.Stringer interface.
+func (a Attempt) String() string {
+	if a.Err != nil {
+		return a.Err.Error()
+	}
+	return fmt.Sprintf("status code %d", a.StatusCode)
+}
+
+// Return a *RetryError records attempts if err isn't nil.
+func retryError(attempts []Attempt, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &RetryError{
+		Attempts: attempts,
+		Err:      err,
+	}
+}
+
+// Error implements error interface.
+func (e *RetryError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "ghttp: giving up after %d attempt(s): %s", len(e.Attempts), e.Err.Error())
+	attempts := e.Attempts
+	// The final attempt's error is usually the one giving up, which is printed already
+	if n := len(attempts); n > 0 && attempts[n-1].Err != nil && attempts[n-1].Err.Error() == e.Err.Error() {
+		attempts = attempts[:n-1]
+	}
+	for i, attempt := range attempts {
+		fmt.Fprintf(&sb, "; #%d: %s", i+1, attempt)
+	}
+	return sb.String()
+}
+
+// Unwrap returns the error that ends the request.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether any attempt's error matches target, used by errors.Is.
+func (e *RetryError) Is(target error) bool {
+	for _, attempt := range e.Attempts {
+		if attempt.Err != nil && errors.Is(attempt.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first attempt's error that matches target, used by errors.As.
+func (e *RetryError) As(target interface{}) bool {
+	for _, attempt := range e.Attempts {
+		if attempt.Err != nil && errors.As(attempt.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // WithRetryMaxAttempts is a retry option that specifies the max attempts to a retrier while 0 means no retries.
 // By default is 3.
 func WithRetryMaxAttempts(n int) RetryOption {
@@ -74,7 +276,8 @@ func WithRetryMaxAttempts(n int) RetryOption {
 }
 
 // WithRetryBackoff is a retry option that specifies the backoff to a retrier.
-// By default is an exponential backoff with jitter whose baseInterval is 1s and maxInterval is 30s.
+// By default is an exponential backoff with jitter whose baseInterval is 1s and maxInterval is 30s,
+// wrapped by a retry-after backoff whose max delay is 5m.
 func WithRetryBackoff(backoff Backoff) RetryOption {
 	return func(r *retrier) {
 		r.backoff = backoff
@@ -89,3 +292,39 @@ func WithRetryTriggers(triggers ...func(resp *Response, err error) bool) RetryOp
 		r.triggers = triggers
 	}
 }
+
+// WithRetryMethods is a retry option that specifies the methods allowed to retry to a retrier.
+// Requests with other methods are retried only if they carry an idempotency key,
+// or fail before being written to the wire.
+// By default are the idempotent methods, i.e. GET, HEAD, OPTIONS, PUT, DELETE and TRACE.
+func WithRetryMethods(methods ...string) RetryOption {
+	return func(r *retrier) {
+		r.methods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			r.methods[method] = true
+		}
+	}
+}
+
+// WithRetryIdempotencyKey is a retry option that makes a retrier inject an Idempotency-Key header,
+// which stays the same across attempts, for requests whose methods aren't allowed to retry,
+// so that they become safe to retry. If the header is already present, it's left untouched.
+// The key is generated by keyFunc while nil means a random UUID.
+func WithRetryIdempotencyKey(keyFunc func() string) RetryOption {
+	return func(r *retrier) {
+		if keyFunc == nil {
+			keyFunc = newUUID
+		}
+		r.idempotencyKey = keyFunc
+	}
+}
+
+// WithRetryPerAttemptTimeout is a retry option that specifies the timeout of every attempt to a retrier,
+// including reading the response body, while 0 means no timeout.
+// An attempt that exceeds the timeout fails with ErrAttemptTimeout and always triggers a retry,
+// while the request context still limits the total time of all attempts.
+func WithRetryPerAttemptTimeout(timeout time.Duration) RetryOption {
+	return func(r *retrier) {
+		r.perAttemptTimeout = timeout
+	}
+}
@@ -2,9 +2,12 @@ package ghttp
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	neturl "net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -98,4 +101,193 @@ func TestRetryOption(t *testing.T) {
 	assert.Equal(t, maxAttempts, retrier.maxAttempts)
 	assert.Equal(t, backoff, retrier.backoff)
 	assert.NotEmpty(t, retrier.triggers)
+
+	WithRetryMethods(MethodPost)(retrier)
+	assert.Equal(t, map[string]bool{MethodPost: true}, retrier.methods)
+
+	WithRetryIdempotencyKey(nil)(retrier)
+	assert.NotNil(t, retrier.idempotencyKey)
+}
+
+func TestRetrier_Safe(t *testing.T) {
+	retrier := defaultRetrier()
+	for _, method := range []string{MethodGet, MethodHead, MethodOptions, MethodPut, MethodDelete} {
+		req, _ := NewRequest(method, "https://httpbin.org")
+		assert.True(t, retrier.safe(req, nil, true))
+	}
+
+	req, _ := NewRequest(MethodPost, "https://httpbin.org/post")
+	assert.False(t, retrier.safe(req, nil, true))
+	assert.False(t, retrier.safe(req, errAccessDummyBody, true))
+	assert.True(t, retrier.safe(req, errAccessDummyBody, false))
+
+	req.Header.Set(IdempotencyKeyHeader, "dummy")
+	assert.True(t, retrier.safe(req, nil, true))
+}
+
+func TestRetrier_IdempotencyKey(t *testing.T) {
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := New()
+	_, err := client.Patch(ts.URL,
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryIdempotencyKey(nil),
+		),
+	)
+	if assert.NoError(t, err) && assert.Len(t, keys, defaultRetryMaxAttempts+1) {
+		assert.Len(t, keys[0], 36)
+		for _, key := range keys {
+			assert.Equal(t, keys[0], key)
+		}
+	}
+
+	keys = keys[:0]
+	_, err = client.Get(ts.URL,
+		WithRetrier(
+			WithRetryMaxAttempts(1),
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryIdempotencyKey(func() string {
+				return "dummy"
+			}),
+		),
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"", ""}, keys)
+	}
+}
+
+func TestClient_RetryNonIdempotent(t *testing.T) {
+	var attempts int
+	countingTrigger := func(resp *Response, err error) bool {
+		attempts++
+		return err != nil
+	}
+	opts := []RetryOption{
+		WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+		WithRetryTriggers(countingTrigger),
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	client := New()
+
+	// The request was written, so the server may have processed it
+	_, err := client.Post(ts.URL, WithRetrier(opts...))
+	if assert.Error(t, err) {
+		assert.Equal(t, 1, attempts)
+	}
+
+	// The server never received the request
+	ts.Close()
+	attempts = 0
+	_, err = client.Post(ts.URL, WithRetrier(opts...))
+	if assert.Error(t, err) {
+		assert.Equal(t, defaultRetryMaxAttempts, attempts)
+	}
+}
+
+func TestRetryError(t *testing.T) {
+	err := retryError([]Attempt{
+		{Err: errAccessDummyBody},
+		{StatusCode: http.StatusServiceUnavailable},
+	}, errAccessDummyBody)
+	want := "ghttp: giving up after 2 attempt(s): " + errAccessDummyBody.Error() +
+		"; #1: " + errAccessDummyBody.Error() + "; #2: status code 503"
+	assert.EqualError(t, err, want)
+	assert.True(t, errors.Is(err, errAccessDummyBody))
+
+	var re *RetryError
+	if assert.True(t, errors.As(err, &re)) {
+		assert.Len(t, re.Attempts, 2)
+	}
+
+	err = retryError([]Attempt{
+		{Err: &neturl.Error{Op: "Get", URL: "https://httpbin.org", Err: ErrAttemptTimeout}},
+		{StatusCode: http.StatusServiceUnavailable},
+	}, context.Canceled)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, errors.Is(err, ErrAttemptTimeout))
+	assert.False(t, errors.Is(err, errAccessDummyBody))
+
+	var ue *neturl.Error
+	if assert.True(t, errors.As(err, &ue)) {
+		assert.Equal(t, "Get", ue.Op)
+	}
+
+	// The final attempt's error is printed once
+	err = retryError([]Attempt{
+		{StatusCode: http.StatusServiceUnavailable},
+		{Err: errAccessDummyBody},
+	}, errAccessDummyBody)
+	assert.EqualError(t, err, "ghttp: giving up after 2 attempt(s): "+errAccessDummyBody.Error()+
+		"; #1: status code 503")
+
+	assert.Nil(t, retryError(nil, nil))
+}
+
+func TestRetrier_PerAttemptTimeout(t *testing.T) {
+	const (
+		timeout = 50 * time.Millisecond
+		n       = 3
+	)
+
+	var counter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint64(&counter, 1) < n || r.URL.Path == "/hang" {
+			select {
+			case <-time.After(4 * timeout):
+			case <-r.Context().Done():
+			}
+		}
+		w.Write([]byte("hello world"))
+	}))
+	defer ts.Close()
+
+	client := New()
+	resp, err := client.Post(ts.URL,
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return false
+			}),
+			WithRetryPerAttemptTimeout(timeout),
+			WithRetryIdempotencyKey(nil),
+		),
+	)
+	if assert.NoError(t, err) {
+		// The response body is still readable after the attempt returned
+		time.Sleep(timeout)
+		data, err := resp.Text()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "hello world", data)
+		}
+		assert.Equal(t, uint64(n), atomic.LoadUint64(&counter))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*timeout)
+	defer cancel()
+	_, err = client.Get(ts.URL+"/hang",
+		WithContext(ctx),
+		WithRetrier(
+			WithRetryMaxAttempts(10),
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryPerAttemptTimeout(timeout),
+		),
+	)
+	var re *RetryError
+	if assert.True(t, errors.As(err, &re)) {
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.True(t, len(re.Attempts) >= 2)
+		assert.True(t, errors.Is(re.Attempts[0].Err, ErrAttemptTimeout))
+	}
 }
@@ -0,0 +1,382 @@
+package ghttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/winterssy/gjson"
+)
+
+const (
+	defaultSSEReconnectDelay = 3 * time.Second
+
+	// The max size of a line in an event stream.
+	maxSSELineSize = 1 << 20
+)
+
+type (
+	// Event is a message received from an event stream (text/event-stream).
+	Event struct {
+		// ID is the last event ID of the stream when the event is dispatched.
+		ID string `json:"id,omitempty"`
+
+		// Event is the event type, by default is "message".
+		Event string `json:"event"`
+
+		// Data is the data of the event, multiple data lines are joined with "\n".
+		Data string `json:"data"`
+
+		// Retry is the reconnection time sent along with the event, if any.
+		Retry time.Duration `json:"retry,omitempty"`
+	}
+
+	// EventStream is an iterator over the events of an event stream.
+	// It's created by Client.SSE, which reconnects automatically when the stream ends,
+	// or by Response.Events, which stops at the end of the response.
+	// Close can be called from another goroutine to interrupt a blocking Next,
+	// the other methods must not be called concurrently.
+	EventStream struct {
+		client      *Client
+		url         string
+		hooks       []RequestHook
+		parent      context.Context
+		ctx         context.Context
+		cancel      context.CancelFunc
+		reconnect   *sseReconnect
+		mu          sync.Mutex
+		resp        *Response
+		scanner     *bufio.Scanner
+		event       *Event
+		lastEventID string
+		retry       time.Duration
+		failures    int
+		done        bool
+		err         error
+	}
+
+	sseReconnect struct {
+		backoff    Backoff
+		maxRetries int
+	}
+)
+
+// WithSSEReconnect is a request hook that specifies how Client.SSE reconnects when the stream
+// ends or the connection fails. The server's retry field takes precedence over backoff if received.
+// maxRetries is the max number of consecutive reconnections without receiving any event,
+// zero disables reconnection and a negative one means no limit.
+// By default, it reconnects after 3 seconds with no limit.
+func WithSSEReconnect(backoff Backoff, maxRetries int) RequestHook {
+	return func(req *Request) error {
+		req.sseReconnect = &sseReconnect{backoff: backoff, maxRetries: maxRetries}
+		return nil
+	}
+}
+
+// SSE subscribes to the event stream at url, the hooks are applied to each (re)connect request.
+// It reconnects automatically with the Last-Event-ID header when the stream ends or the connection
+// fails, see WithSSEReconnect for more details. A response with other status than 200 OK or
+// content type than text/event-stream stops the stream with an error, it's never reconnected.
+// The stream can be cancelled through the request's context or by EventStream.Close.
+// Note that Client.Timeout also applies to the stream, it should be zero for long-lived streams.
+func (c *Client) SSE(url string, hooks ...RequestHook) (*EventStream, error) {
+	es := &EventStream{
+		client: c,
+		url:    url,
+		hooks:  hooks,
+		reconnect: &sseReconnect{
+			backoff:    NewConstantBackoff(defaultSSEReconnectDelay, false),
+			maxRetries: -1,
+		},
+	}
+	req, err := es.newRequest()
+	if err != nil {
+		return nil, err
+	}
+	if req.sseReconnect != nil {
+		es.reconnect = req.sseReconnect
+	}
+	es.parent = req.Context()
+	es.ctx, es.cancel = context.WithCancel(es.parent)
+	req.Request = req.WithContext(es.ctx)
+
+	resp, err := es.client.Do(req)
+	if err != nil {
+		es.cancel()
+		return nil, err
+	}
+	ok, err := es.accept(resp)
+	if err != nil {
+		es.cancel()
+		return nil, err
+	}
+	if ok {
+		es.open(resp)
+	} else {
+		es.stop(nil)
+	}
+	return es, nil
+}
+
+// Events returns an iterator over the events in resp's body, it doesn't reconnect.
+func (resp *Response) Events() *EventStream {
+	es := &EventStream{parent: context.Background()}
+	if resp.Request != nil {
+		es.parent = resp.Request.Context()
+	}
+	es.ctx, es.cancel = context.WithCancel(es.parent)
+	es.open(resp)
+	return es
+}
+
+// Next advances to the next event, which is available through Event.
+// It returns false when the stream ends, fails or is closed, see Err for the error.
+func (es *EventStream) Next() bool {
+	for !es.done {
+		if es.scanner != nil {
+			if event, ok := es.readEvent(); ok {
+				es.event = event
+				es.failures = 0
+				return true
+			}
+			err := es.scanner.Err()
+			es.closeBody()
+			if !es.wait(err) {
+				return false
+			}
+		}
+		es.reconnectStream()
+	}
+	return false
+}
+
+// Event returns the current event, which is advanced by Next.
+func (es *EventStream) Event() *Event {
+	return es.event
+}
+
+// Err returns the error that stopped the stream, it's nil if the stream ends normally,
+// e.g. the server responds 204 No Content or the reconnection is disabled.
+func (es *EventStream) Err() error {
+	return es.err
+}
+
+// LastEventID returns the last event ID of the stream, which is sent as the Last-Event-ID header
+// when reconnecting.
+func (es *EventStream) LastEventID() string {
+	return es.lastEventID
+}
+
+// Close stops the stream and closes the underlying connection.
+func (es *EventStream) Close() error {
+	es.cancel()
+	return es.closeBody()
+}
+
+func (es *EventStream) closeBody() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.resp == nil {
+		return nil
+	}
+	err := es.resp.Body.Close()
+	es.resp = nil
+	return err
+}
+
+func (es *EventStream) newRequest() (*Request, error) {
+	req, err := NewRequest(MethodGet, es.url)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range es.hooks {
+		if err = hook(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if es.ctx != nil {
+		req.Request = req.WithContext(es.ctx)
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if es.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", es.lastEventID)
+	}
+	return req, nil
+}
+
+func (es *EventStream) reconnectStream() {
+	req, err := es.newRequest()
+	if err != nil {
+		es.stop(err)
+		return
+	}
+
+	resp, err := es.client.Do(req)
+	if err != nil {
+		// Only the network errors are reconnected
+		es.wait(err)
+		return
+	}
+	ok, err := es.accept(resp)
+	switch {
+	case err != nil:
+		es.stop(err)
+	case !ok:
+		es.stop(nil)
+	default:
+		es.open(resp)
+	}
+}
+
+// Check if resp is an event stream. It reports false without error if the server responds
+// 204 No Content, which means the stream should not be reconnected.
+// Other status than 200 OK or content type than text/event-stream fails the stream,
+// it should not be reconnected either.
+func (es *EventStream) accept(resp *Response) (bool, error) {
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		resp.Body.Close()
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return false, fmt.Errorf("ghttp: event stream responds %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		resp.Body.Close()
+		return false, fmt.Errorf("ghttp: event stream responds unexpected content type %q", mediaType)
+	}
+	return true, nil
+}
+
+func (es *EventStream) open(resp *Response) {
+	es.mu.Lock()
+	es.resp = resp
+	es.mu.Unlock()
+	es.scanner = bufio.NewScanner(resp.Body)
+	es.scanner.Buffer(nil, maxSSELineSize)
+	es.scanner.Split(scanEventLines)
+}
+
+// Wait before reconnecting after err, which is nil if the stream ends normally.
+// It reports whether to reconnect, the stream is stopped if not.
+func (es *EventStream) wait(err error) bool {
+	es.scanner = nil
+	if es.ctx.Err() != nil || es.client == nil ||
+		es.reconnect.maxRetries >= 0 && es.failures >= es.reconnect.maxRetries {
+		es.stop(err)
+		return false
+	}
+
+	delay := es.retry
+	if delay <= 0 {
+		delay = es.reconnect.backoff.Wait(es.failures, nil, err)
+	}
+	es.failures++
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-es.ctx.Done():
+		es.stop(err)
+		return false
+	}
+}
+
+// Stop the stream with err. If the stream is cancelled, err is replaced with the error of
+// the request's context, which is nil if it's closed by Close.
+func (es *EventStream) stop(err error) {
+	es.done = true
+	if es.ctx.Err() != nil {
+		err = es.parent.Err()
+	}
+	es.err = err
+	es.cancel()
+	es.closeBody()
+}
+
+// Read the lines until an event is dispatched, it returns false if the stream ends before that.
+// The incomplete event at the end of the stream is discarded.
+func (es *EventStream) readEvent() (*Event, bool) {
+	event := &Event{}
+	var data bytes.Buffer
+	for es.scanner.Scan() {
+		line := es.scanner.Text()
+		if line == "" {
+			if data.Len() == 0 {
+				event = &Event{}
+				continue
+			}
+			event.ID = es.lastEventID
+			event.Event = valueOrDefault(event.Event, "message")
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			return event, true
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := splitPair(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				es.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 32); err == nil && strings.Trim(value, "0123456789") == "" {
+				es.retry = time.Duration(ms) * time.Millisecond
+				event.Retry = es.retry
+			}
+		}
+	}
+	return nil, false
+}
+
+// JSON unmarshals the JSON-encoded data of e into v.
+func (e *Event) JSON(v interface{}, opts ...func(dec *gjson.Decoder)) error {
+	return gjson.DecodeFromString(e.Data, v, opts...)
+}
+
+// A split function for bufio.Scanner, the lines of an event stream end with "\r\n", "\n" or "\r".
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// Request more data to see if it's "\r\n"
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,217 @@
+package ghttp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanEventLines(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("a\r\nb\nc\rd\r\r\ne"))
+	scanner.Split(scanEventLines)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"a", "b", "c", "d", "", "e"}, lines)
+}
+
+func TestResponse_Events(t *testing.T) {
+	const stream = ": this is a comment\n" +
+		"data: first\n" +
+		"data:  second\n" +
+		"\n" +
+		"event: update\r\n" +
+		"id: 1\r\n" +
+		"retry: 1500\r\n" +
+		"data: {\"n\":1}\r\n" +
+		"\r\n" +
+		"id\n" +
+		"data\n" +
+		"\n" +
+		"id: bad\x00\n" +
+		"retry: 1s\n" +
+		"unknown: field\n" +
+		"data:no space\r" +
+		"\r" +
+		"event: ignored\n" +
+		"\n" +
+		"data: incomplete"
+	resp := &Response{
+		Response: &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(stream)),
+		},
+	}
+
+	var events []*Event
+	es := resp.Events()
+	for es.Next() {
+		events = append(events, es.Event())
+	}
+	require.NoError(t, es.Err())
+	assert.NoError(t, es.Close())
+	assert.Equal(t, []*Event{
+		{Event: "message", Data: "first\n second"},
+		{ID: "1", Event: "update", Data: `{"n":1}`, Retry: 1500 * time.Millisecond},
+		{Event: "message"},
+		{Event: "message", Data: "no space"},
+	}, events)
+
+	var v struct {
+		N int `json:"n"`
+	}
+	require.NoError(t, events[1].JSON(&v))
+	assert.Equal(t, 1, v.N)
+}
+
+func TestClient_SSE(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		connects     int
+		lastEventIDs []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connects++
+		n := connects
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		assert.Equal(t, "bar", r.Header.Get("X-Foo"))
+
+		if n == 4 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		switch n {
+		case 1:
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: one\n\nid: 2\ndata: two\n\n")
+		case 2:
+			fmt.Fprint(w, "data: incomplete")
+		default:
+			fmt.Fprint(w, "id: 3\ndata: three\n\n")
+		}
+		w.(http.Flusher).Flush()
+	}))
+	defer ts.Close()
+
+	client := New()
+	es, err := client.SSE(ts.URL,
+		WithHeaders(Headers{"X-Foo": "bar"}),
+		WithSSEReconnect(NewConstantBackoff(time.Hour, false), 3),
+	)
+	require.NoError(t, err)
+	defer es.Close()
+
+	var data []string
+	for es.Next() {
+		data = append(data, es.Event().Data)
+	}
+	require.NoError(t, es.Err())
+	assert.Equal(t, []string{"one", "two", "three"}, data)
+	assert.Equal(t, "3", es.LastEventID())
+	assert.Equal(t, []string{"", "2", "2", "3"}, lastEventIDs)
+}
+
+func TestClient_SSEMaxRetries(t *testing.T) {
+	var connects uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddUint64(&connects, 1) == 1 {
+			fmt.Fprint(w, "data: hello\n\n")
+		}
+	}))
+	defer ts.Close()
+
+	client := New()
+	es, err := client.SSE(ts.URL, WithSSEReconnect(NewConstantBackoff(time.Millisecond, false), 2))
+	require.NoError(t, err)
+	defer es.Close()
+
+	require.True(t, es.Next())
+	assert.False(t, es.Next())
+	assert.NoError(t, es.Err())
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&connects))
+}
+
+func TestClient_SSEBadStatus(t *testing.T) {
+	var connects uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint64(&connects, 1) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+	}))
+	defer ts.Close()
+
+	client := New()
+	es, err := client.SSE(ts.URL, WithSSEReconnect(NewConstantBackoff(time.Millisecond, false), -1))
+	require.NoError(t, err)
+	defer es.Close()
+
+	require.True(t, es.Next())
+	assert.False(t, es.Next())
+	assert.EqualError(t, es.Err(), "ghttp: event stream responds 500 Internal Server Error")
+	assert.Equal(t, uint64(2), atomic.LoadUint64(&connects))
+
+	_, err = client.SSE(ts.URL)
+	assert.EqualError(t, err, "ghttp: event stream responds 500 Internal Server Error")
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&connects))
+}
+
+func TestClient_SSEUnexpectedContentType(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	client := New()
+	_, err := client.SSE(ts.URL)
+	assert.EqualError(t, err, `ghttp: event stream responds unexpected content type "application/json"`)
+}
+
+func TestClient_SSECancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	client := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	es, err := client.SSE(ts.URL, WithContext(ctx))
+	require.NoError(t, err)
+
+	require.True(t, es.Next())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	assert.False(t, es.Next())
+	assert.Equal(t, context.Canceled, es.Err())
+
+	es, err = client.SSE(ts.URL)
+	require.NoError(t, err)
+	require.True(t, es.Next())
+	time.AfterFunc(50*time.Millisecond, func() {
+		es.Close()
+	})
+	assert.False(t, es.Next())
+	assert.NoError(t, es.Err())
+}
@@ -2,25 +2,144 @@ package ghttp
 
 import (
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/http/httptrace"
+	neturl "net/url"
+	"sync"
 	"time"
 )
 
+var (
+	tlsVersionNames = map[uint16]string{
+		tls.VersionSSL30: "SSL 3.0",
+		tls.VersionTLS10: "TLS 1.0",
+		tls.VersionTLS11: "TLS 1.1",
+		tls.VersionTLS12: "TLS 1.2",
+		tls.VersionTLS13: "TLS 1.3",
+	}
+
+	cipherSuiteNames = map[uint16]string{
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA:            "TLS_RSA_WITH_AES_128_CBC_SHA",
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA:            "TLS_RSA_WITH_AES_256_CBC_SHA",
+		tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         "TLS_RSA_WITH_AES_128_GCM_SHA256",
+		tls.TLS_RSA_WITH_AES_256_GCM_SHA384:         "TLS_RSA_WITH_AES_256_GCM_SHA384",
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:    "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305",
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305",
+		tls.TLS_AES_128_GCM_SHA256:                  "TLS_AES_128_GCM_SHA256",
+		tls.TLS_AES_256_GCM_SHA384:                  "TLS_AES_256_GCM_SHA384",
+		tls.TLS_CHACHA20_POLY1305_SHA256:            "TLS_CHACHA20_POLY1305_SHA256",
+	}
+)
+
 type (
 	clientTrace struct {
 		start                time.Time
+		hopStart             time.Time
 		dnsStart             time.Time
 		dnsDone              time.Time
-		connStart            time.Time
-		connDone             time.Time
 		tlsHandshakeStart    time.Time
 		tlsHandshakeDone     time.Time
 		gotFirstResponseByte time.Time
+		wroteHeaders         time.Time
 		wroteRequest         time.Time
 		end                  time.Time
+		bodyDone             time.Time
 		getConn              time.Time
 		gotConn              time.Time
 		gotConnInfo          httptrace.GotConnInfo
+		dnsDoneInfo          httptrace.DNSDoneInfo
+		tlsState             *tls.ConnectionState
+
+		// Guards the connect fields, which are set by the concurrent dials, e.g. dual-stack ones
+		mu            *sync.Mutex
+		connStart     time.Time
+		connDone      time.Time
+		connectErrors []string
+
+		// The finished hops before the current one, i.e. the requests redirected from
+		hops []*clientTrace
+	}
+
+	// A hop of an attempt, i.e. the original request or a redirect of it.
+	traceHop struct {
+		method     string
+		url        string
+		statusCode int
+	}
+
+	// The trace of an attempt, which has a hop per redirect.
+	attemptTrace struct {
+		ct      *clientTrace
+		attempt int
+		hops    []traceHop
+		err     error
+	}
+
+	// TraceEntry is the trace info of an attempt of a request, or a redirect of an attempt.
+	TraceEntry struct {
+		// Attempt is the attempt number, starting from 1.
+		Attempt int `json:"attempt"`
+
+		// Redirect is the number of redirects followed before the request, 0 for the original one.
+		Redirect int `json:"redirect"`
+
+		Method     string `json:"method,omitempty"`
+		URL        string `json:"url,omitempty"`
+		StatusCode int    `json:"status_code,omitempty"`
+
+		// Err is the error of the attempt if it fails, it's only set on the last entry of an attempt.
+		Err error `json:"-"`
+
+		// The timings of the entry, TotalTime is the duration of the entry itself.
+		TraceInfo
+	}
+
+	// TraceTotal is the aggregation of the entries of a trace history.
+	TraceTotal struct {
+		Attempts  int `json:"attempts"`
+		Redirects int `json:"redirects"`
+
+		// The sums of the durations of all entries.
+		DNSLookupTime    time.Duration `json:"dns_lookup_time"`
+		TCPConnTime      time.Duration `json:"tcp_conn_time"`
+		TLSHandshakeTime time.Duration `json:"tls_handshake_time"`
+		ConnTime         time.Duration `json:"conn_time"`
+		ServerTime       time.Duration `json:"server_time"`
+		ResponseTime     time.Duration `json:"response_time"`
+
+		// BackoffTime is a duration that waited between the attempts.
+		BackoffTime time.Duration `json:"backoff_time"`
+
+		// TotalTime is a duration since the first attempt starts to the last one ends.
+		TotalTime time.Duration `json:"total_time"`
+
+		// ConnReused is the number of entries which reused a connection.
+		ConnReused int `json:"conn_reused"`
+	}
+
+	// TraceHistory is the trace info of all attempts and redirects of a request.
+	TraceHistory struct {
+		Entries []*TraceEntry `json:"entries"`
+		Total   TraceTotal    `json:"total"`
+	}
+
+	// CertificateInfo is a summary of a certificate presented by the server.
+	CertificateInfo struct {
+		Subject   string    `json:"subject"`
+		Issuer    string    `json:"issuer"`
+		DNSNames  []string  `json:"dns_names,omitempty"`
+		NotBefore time.Time `json:"not_before"`
+		NotAfter  time.Time `json:"not_after"`
 	}
 
 	// TraceInfo is used to provide request trace info such as DNS lookup
@@ -60,15 +179,61 @@ type (
 		// ConnIdleTime is a duration how long the connection was previously
 		// idle, if ConnWasIdle is true.
 		ConnIdleTime time.Duration `json:"conn_idle_time"`
+
+		// RequestBodyTime is a duration that took to write the request body.
+		RequestBodyTime time.Duration `json:"request_body_time,omitempty"`
+
+		// ResponseBodyTime is a duration since the response headers are received to
+		// the response body is read completely by Response.Content, Response.JSON,
+		// Response.SaveFile, etc. It's zero if the body isn't read yet.
+		ResponseBodyTime time.Duration `json:"response_body_time,omitempty"`
+
+		// ResolvedIPs are the IP addresses resolved by DNS lookup.
+		ResolvedIPs []string `json:"resolved_ips,omitempty"`
+
+		// ConnectErrors are the errors of the failed dials, e.g. when an IPv6 address
+		// is unreachable and falls back to IPv4.
+		ConnectErrors []string `json:"connect_errors,omitempty"`
+
+		// RemoteAddr is the remote address of the connection.
+		RemoteAddr string `json:"remote_addr,omitempty"`
+
+		// LocalAddr is the local address of the connection.
+		LocalAddr string `json:"local_addr,omitempty"`
+
+		// Protocol is the protocol of the response, e.g. "HTTP/1.1" or "HTTP/2.0".
+		Protocol string `json:"protocol,omitempty"`
+
+		// TLSVersion is the negotiated TLS version, e.g. "TLS 1.3".
+		TLSVersion string `json:"tls_version,omitempty"`
+
+		// TLSCipherSuite is the negotiated cipher suite, e.g. "TLS_AES_128_GCM_SHA256".
+		TLSCipherSuite string `json:"tls_cipher_suite,omitempty"`
+
+		// TLSResumed reports whether the TLS session was resumed from a previous connection.
+		TLSResumed bool `json:"tls_resumed,omitempty"`
+
+		// PeerCertificates are the summaries of the certificate chain presented by the server,
+		// the first one is the leaf certificate.
+		PeerCertificates []CertificateInfo `json:"peer_certificates,omitempty"`
 	}
 )
 
 func (ct *clientTrace) modifyRequest(req *Request) {
+	if ct.mu == nil {
+		ct.mu = new(sync.Mutex)
+	}
 	ctx := httptrace.WithClientTrace(
 		req.Context(),
 		&httptrace.ClientTrace{
 			GetConn: func(_ string) {
-				ct.getConn = time.Now()
+				now := time.Now()
+				// A redirect starts, the transport may get a connection again for the same
+				// request when retrying internally, but there's no response then
+				if !ct.gotFirstResponseByte.IsZero() {
+					ct.nextHop(now)
+				}
+				ct.getConn = now
 			},
 			GotConn: func(gotConnInfo httptrace.GotConnInfo) {
 				ct.gotConn = time.Now()
@@ -80,20 +245,34 @@ func (ct *clientTrace) modifyRequest(req *Request) {
 			DNSStart: func(_ httptrace.DNSStartInfo) {
 				ct.dnsStart = time.Now()
 			},
-			DNSDone: func(_ httptrace.DNSDoneInfo) {
+			DNSDone: func(dnsDoneInfo httptrace.DNSDoneInfo) {
 				ct.dnsDone = time.Now()
+				ct.dnsDoneInfo = dnsDoneInfo
 			},
 			ConnectStart: func(network, addr string) {
+				ct.mu.Lock()
 				ct.connStart = time.Now()
+				ct.mu.Unlock()
 			},
 			ConnectDone: func(network, addr string, err error) {
+				ct.mu.Lock()
+				defer ct.mu.Unlock()
 				ct.connDone = time.Now()
+				if err != nil {
+					ct.connectErrors = append(ct.connectErrors, fmt.Sprintf("%s %s: %s", network, addr, err.Error()))
+				}
 			},
 			TLSHandshakeStart: func() {
 				ct.tlsHandshakeStart = time.Now()
 			},
-			TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			TLSHandshakeDone: func(state tls.ConnectionState, err error) {
 				ct.tlsHandshakeDone = time.Now()
+				if err == nil {
+					ct.tlsState = &state
+				}
+			},
+			WroteHeaders: func() {
+				ct.wroteHeaders = time.Now()
 			},
 			WroteRequest: func(_ httptrace.WroteRequestInfo) {
 				ct.wroteRequest = time.Now()
@@ -107,8 +286,40 @@ func (ct *clientTrace) done() {
 	ct.end = time.Now()
 }
 
+// Finish the current hop at now and start a new one.
+func (ct *clientTrace) nextHop(now time.Time) {
+	hop := *ct
+	hop.start = ct.hopStartTime()
+	hop.end = now
+	hop.hops = nil
+	*ct = clientTrace{
+		start:    ct.start,
+		hopStart: now,
+		mu:       ct.mu,
+		hops:     append(ct.hops, &hop),
+	}
+}
+
+func (ct *clientTrace) hopStartTime() time.Time {
+	if ct.hopStart.IsZero() {
+		return ct.start
+	}
+	return ct.hopStart
+}
+
+// Record the time that the response body is read completely.
+func (ct *clientTrace) readBody() {
+	if ct.bodyDone.IsZero() {
+		ct.bodyDone = time.Now()
+	}
+}
+
 func (ct *clientTrace) traceInfo() *TraceInfo {
-	return &TraceInfo{
+	if ct.mu != nil {
+		ct.mu.Lock()
+		defer ct.mu.Unlock()
+	}
+	info := &TraceInfo{
 		DNSLookupTime:    ct.dnsDone.Sub(ct.dnsStart),
 		TCPConnTime:      ct.connDone.Sub(ct.connStart),
 		TLSHandshakeTime: ct.tlsHandshakeDone.Sub(ct.tlsHandshakeStart),
@@ -119,5 +330,122 @@ func (ct *clientTrace) traceInfo() *TraceInfo {
 		ConnReused:       ct.gotConnInfo.Reused,
 		ConnWasIdle:      ct.gotConnInfo.WasIdle,
 		ConnIdleTime:     ct.gotConnInfo.IdleTime,
+		ConnectErrors:    append([]string(nil), ct.connectErrors...),
+	}
+	if !ct.wroteHeaders.IsZero() && !ct.wroteRequest.IsZero() {
+		info.RequestBodyTime = ct.wroteRequest.Sub(ct.wroteHeaders)
+	}
+	if !ct.bodyDone.IsZero() {
+		info.ResponseBodyTime = ct.bodyDone.Sub(ct.end)
+	}
+	for _, addr := range ct.dnsDoneInfo.Addrs {
+		info.ResolvedIPs = append(info.ResolvedIPs, addr.String())
+	}
+	if conn := ct.gotConnInfo.Conn; conn != nil {
+		info.RemoteAddr = conn.RemoteAddr().String()
+		info.LocalAddr = conn.LocalAddr().String()
+	}
+
+	if state := ct.tlsState; state != nil {
+		info.TLSVersion = valueOrDefault(tlsVersionNames[state.Version], fmt.Sprintf("0x%04X", state.Version))
+		info.TLSCipherSuite = valueOrDefault(cipherSuiteNames[state.CipherSuite], fmt.Sprintf("0x%04X", state.CipherSuite))
+		info.TLSResumed = state.DidResume
+		for _, cert := range state.PeerCertificates {
+			info.PeerCertificates = append(info.PeerCertificates, CertificateInfo{
+				Subject:   cert.Subject.String(),
+				Issuer:    cert.Issuer.String(),
+				DNSNames:  cert.DNSNames,
+				NotBefore: cert.NotBefore,
+				NotAfter:  cert.NotAfter,
+			})
+		}
+	}
+	return info
+}
+
+// Return the trace of an attempt of req, which ends with resp or err.
+func newAttemptTrace(ct *clientTrace, attemptNum int, req *Request, resp *http.Response, err error) *attemptTrace {
+	at := &attemptTrace{ct: ct, attempt: attemptNum + 1, err: err}
+	if resp == nil {
+		// The URL of the failed redirect is unknown unless reported by the error
+		url := req.URL.String()
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			url = urlErr.URL
+		}
+		at.hops = []traceHop{{method: req.Method, url: url}}
+		return at
+	}
+
+	// Walk back through the redirect responses which caused the requests
+	statusCode := resp.StatusCode
+	r := resp.Request
+	if r == nil {
+		r = req.Request
+	}
+	for r != nil {
+		at.hops = append(at.hops, traceHop{method: r.Method, url: r.URL.String(), statusCode: statusCode})
+		if r.Response == nil {
+			break
+		}
+		statusCode = r.Response.StatusCode
+		r = r.Response.Request
+	}
+	for i, j := 0, len(at.hops)-1; i < j; i, j = i+1, j-1 {
+		at.hops[i], at.hops[j] = at.hops[j], at.hops[i]
+	}
+	return at
+}
+
+func (at *attemptTrace) entries() []*TraceEntry {
+	cts := append(at.ct.hops[:len(at.ct.hops):len(at.ct.hops)], at.ct)
+	entries := make([]*TraceEntry, len(cts))
+	for i, ct := range cts {
+		entry := &TraceEntry{
+			Attempt:   at.attempt,
+			Redirect:  i,
+			TraceInfo: *ct.traceInfo(),
+		}
+		entry.TotalTime = ct.end.Sub(ct.hopStartTime())
+		// Align the hops from the last one, the earlier ones may be unknown if the attempt fails
+		if j := len(at.hops) - len(cts) + i; j >= 0 {
+			entry.Method = at.hops[j].method
+			entry.URL = at.hops[j].url
+			entry.StatusCode = at.hops[j].statusCode
+		}
+		entries[i] = entry
+	}
+	entries[len(entries)-1].Err = at.err
+	return entries
+}
+
+func newTraceHistory(traces []*attemptTrace, attempts []Attempt) *TraceHistory {
+	th := &TraceHistory{}
+	for _, at := range traces {
+		th.Entries = append(th.Entries, at.entries()...)
+	}
+
+	total := &th.Total
+	total.Attempts = len(traces)
+	for _, entry := range th.Entries {
+		if entry.Redirect > 0 {
+			total.Redirects++
+		}
+		if entry.ConnReused {
+			total.ConnReused++
+		}
+		total.DNSLookupTime += entry.DNSLookupTime
+		total.TCPConnTime += entry.TCPConnTime
+		total.TLSHandshakeTime += entry.TLSHandshakeTime
+		total.ConnTime += entry.ConnTime
+		total.ServerTime += entry.ServerTime
+		total.ResponseTime += entry.ResponseTime
+	}
+	for _, attempt := range attempts {
+		total.BackoffTime += attempt.Backoff
+	}
+	if n := len(traces); n > 0 {
+		total.TotalTime = traces[n-1].ct.end.Sub(traces[0].ct.start)
 	}
+	return th
 }
@@ -0,0 +1,74 @@
+package ghttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTrace_TraceInfo(t *testing.T) {
+	start := time.Now()
+	ct := &clientTrace{
+		start:        start,
+		wroteHeaders: start.Add(time.Millisecond),
+		wroteRequest: start.Add(3 * time.Millisecond),
+		end:          start.Add(5 * time.Millisecond),
+		dnsDoneInfo: httptrace.DNSDoneInfo{Addrs: []net.IPAddr{
+			{IP: net.ParseIP("127.0.0.1")},
+			{IP: net.ParseIP("::1")},
+		}},
+		connectErrors: []string{"tcp [::1]:443: connection refused"},
+		tlsState: &tls.ConnectionState{
+			Version:     tls.VersionTLS12,
+			CipherSuite: 0xffff,
+			DidResume:   true,
+		},
+	}
+
+	info := ct.traceInfo()
+	assert.Equal(t, 2*time.Millisecond, info.RequestBodyTime)
+	assert.Zero(t, info.ResponseBodyTime)
+	assert.Equal(t, []string{"127.0.0.1", "::1"}, info.ResolvedIPs)
+	assert.Equal(t, []string{"tcp [::1]:443: connection refused"}, info.ConnectErrors)
+	assert.Empty(t, info.RemoteAddr)
+	assert.Equal(t, "TLS 1.2", info.TLSVersion)
+	assert.Equal(t, "0xFFFF", info.TLSCipherSuite)
+	assert.True(t, info.TLSResumed)
+
+	ct.readBody()
+	first := ct.bodyDone
+	ct.readBody()
+	assert.Equal(t, first, ct.bodyDone)
+	assert.Equal(t, first.Sub(ct.end), ct.traceInfo().ResponseBodyTime)
+}
+
+func TestClientTrace_ConcurrentDials(t *testing.T) {
+	req, err := NewRequest(MethodGet, "https://example.com")
+	require.NoError(t, err)
+
+	ct := &clientTrace{start: time.Now()}
+	ct.modifyRequest(req)
+	trace := httptrace.ContextClientTrace(req.Context())
+	require.NotNil(t, trace)
+
+	const n = 8
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			trace.ConnectStart("tcp", "[::1]:443")
+			trace.ConnectDone("tcp", "[::1]:443", errors.New("connection refused"))
+			ct.traceInfo()
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, ct.traceInfo().ConnectErrors, n)
+}
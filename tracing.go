@@ -0,0 +1,368 @@
+package ghttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerTraceparent  = "Traceparent"
+	headerTracestate   = "Tracestate"
+	headerB3TraceID    = "X-B3-Traceid"
+	headerB3SpanID     = "X-B3-Spanid"
+	headerB3ParentSpan = "X-B3-Parentspanid"
+	headerB3Sampled    = "X-B3-Sampled"
+)
+
+// Names of the spans.
+const (
+	SpanNameAttempt      = "attempt"
+	SpanNameDNSLookup    = "dns_lookup"
+	SpanNameTCPConnect   = "tcp_connect"
+	SpanNameTLSHandshake = "tls_handshake"
+)
+
+type (
+	// TraceID is the identifier of a trace.
+	TraceID [16]byte
+
+	// SpanID is the identifier of a span.
+	SpanID [8]byte
+
+	// SpanContext is the part of a span which is propagated to the downstream services.
+	SpanContext struct {
+		TraceID    TraceID
+		SpanID     SpanID
+		Sampled    bool
+		TraceState string
+	}
+
+	// Span is a timed operation of a request, such as the request itself, an attempt of it and
+	// the DNS lookup, TCP connect and TLS handshake phases of an attempt.
+	Span struct {
+		Name         string                 `json:"name"`
+		SpanContext  SpanContext            `json:"span_context"`
+		ParentSpanID SpanID                 `json:"parent_span_id"`
+		StartTime    time.Time              `json:"start_time"`
+		EndTime      time.Time              `json:"end_time"`
+		Attributes   map[string]interface{} `json:"attributes,omitempty"`
+		Err          error                  `json:"-"`
+	}
+
+	// SpanExporter is the interface that exports the ended spans.
+	// Implementations must be safe for concurrent use by multiple goroutines.
+	SpanExporter interface {
+		ExportSpan(span *Span)
+	}
+
+	// SpanExporterFunc is an adapter to allow the use of ordinary functions as SpanExporter.
+	SpanExporterFunc func(span *Span)
+
+	// InMemoryExporter is a SpanExporter which keeps the spans in memory, it's useful for tests.
+	InMemoryExporter struct {
+		mu    sync.Mutex
+		spans []*Span
+	}
+
+	// TracingOption configures the tracing enabled by Client.EnableTracing.
+	TracingOption func(t *tracer)
+
+	tracer struct {
+		exporter    SpanExporter
+		b3          bool
+		redactQuery map[string]bool
+	}
+
+	spanContextKey struct{}
+
+	requestSpanKey struct{}
+)
+
+// String returns the hex encoding of tid, implements fmt.Stringer interface.
+func (tid TraceID) String() string {
+	return hex.EncodeToString(tid[:])
+}
+
+// IsValid reports whether tid is not all zeros.
+func (tid TraceID) IsValid() bool {
+	return tid != TraceID{}
+}
+
+// String returns the hex encoding of sid, implements fmt.Stringer interface.
+func (sid SpanID) String() string {
+	return hex.EncodeToString(sid[:])
+}
+
+// IsValid reports whether sid is not all zeros.
+func (sid SpanID) IsValid() bool {
+	return sid != SpanID{}
+}
+
+// IsValid reports whether sc has a valid trace ID and span ID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Duration returns the duration of s.
+func (s *Span) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// ExportSpan implements SpanExporter interface.
+func (f SpanExporterFunc) ExportSpan(span *Span) {
+	f(span)
+}
+
+// NewInMemoryExporter returns a new InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan implements SpanExporter interface.
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+}
+
+// Spans returns the exported spans in the order they ended,
+// that is, the child spans come before their parents.
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make([]*Span, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset removes all exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// ContextWithSpanContext returns a copy of ctx with sc as the parent of the spans of the requests
+// made with it, e.g. the span context extracted from an incoming request.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context in ctx if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// ExtractSpanContext extracts the span context from the W3C traceparent and tracestate headers,
+// or the B3 headers if there's no traceparent header. It reports whether a valid one is found.
+func ExtractSpanContext(h http.Header) (SpanContext, bool) {
+	if traceparent := h.Get(headerTraceparent); traceparent != "" {
+		sc, ok := parseTraceparent(traceparent)
+		if ok {
+			sc.TraceState = strings.Join(h[headerTracestate], ",")
+		}
+		return sc, ok
+	}
+
+	var sc SpanContext
+	traceID := h.Get(headerB3TraceID)
+	if len(traceID) == 16 {
+		// The 64-bit trace IDs are left-padded with zeros
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !decodeHexID(sc.TraceID[:], traceID) || !decodeHexID(sc.SpanID[:], h.Get(headerB3SpanID)) {
+		return SpanContext{}, false
+	}
+	sampled := h.Get(headerB3Sampled)
+	sc.Sampled = sampled == "1" || sampled == "true" || h.Get("X-B3-Flags") == "1"
+	return sc, sc.IsValid()
+}
+
+func parseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+
+	var version, flags [1]byte
+	if !decodeHexID(version[:], parts[0]) ||
+		!decodeHexID(sc.TraceID[:], parts[1]) ||
+		!decodeHexID(sc.SpanID[:], parts[2]) ||
+		!decodeHexID(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// Decode the lowercase hex string s into dst, which must be exactly len(dst) bytes.
+func decodeHexID(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func newSpanID() (sid SpanID) {
+	_, _ = rand.Read(sid[:])
+	return
+}
+
+func newTraceID() (tid TraceID) {
+	_, _ = rand.Read(tid[:])
+	return
+}
+
+func newTracer(exporter SpanExporter, opts []TracingOption) *tracer {
+	t := &tracer{
+		exporter:    exporter,
+		redactQuery: newRedactor(nil).redactQuery,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// WithTracingB3 is a tracing option that injects the B3 headers used by Zipkin as well as
+// the W3C traceparent and tracestate headers.
+func WithTracingB3() TracingOption {
+	return func(t *tracer) {
+		t.b3 = true
+	}
+}
+
+func (t *tracer) startSpan(name string, parent SpanContext, start time.Time) *Span {
+	span := &Span{
+		Name:       name,
+		StartTime:  start,
+		Attributes: make(map[string]interface{}),
+	}
+	span.SpanContext.SpanID = newSpanID()
+	if parent.IsValid() {
+		span.SpanContext.TraceID = parent.TraceID
+		span.SpanContext.Sampled = parent.Sampled
+		span.SpanContext.TraceState = parent.TraceState
+		span.ParentSpanID = parent.SpanID
+	} else {
+		span.SpanContext.TraceID = newTraceID()
+		span.SpanContext.Sampled = true
+	}
+	return span
+}
+
+func (t *tracer) endSpan(span *Span, end time.Time, err error) {
+	span.EndTime = end
+	span.Err = err
+	if err != nil {
+		span.Attributes["error"] = err.Error()
+	}
+	if span.SpanContext.Sampled {
+		t.exporter.ExportSpan(span)
+	}
+}
+
+func (t *tracer) wrap(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		parent, _ := SpanContextFromContext(req.Context())
+		span := t.startSpan("HTTP "+req.Method, parent, time.Now())
+		span.Attributes["http.method"] = req.Method
+		span.Attributes["http.url"] = maskQuery(req.URL, t.redactQuery).String()
+		req.EnableClientTrace()
+		req.Request = req.WithContext(context.WithValue(req.Context(), requestSpanKey{}, span))
+
+		resp, err := next(req)
+		if resp != nil && resp.Response != nil {
+			span.Attributes["http.status_code"] = resp.StatusCode
+		}
+		t.endSpan(span, time.Now(), err)
+		return resp, err
+	}
+}
+
+func (t *tracer) wrapAttempt(next Handler) Handler {
+	return func(req *Request) (*Response, error) {
+		span := t.startAttempt(req, req.AttemptNum())
+		resp, err := next(req)
+		t.endAttempt(span, resp, err)
+		return resp, err
+	}
+}
+
+// Start a child span of the request span for an attempt of req and propagate it through the headers.
+// It returns nil if req isn't traced.
+func (t *tracer) startAttempt(req *Request, attemptNum int) *Span {
+	parent, ok := req.Context().Value(requestSpanKey{}).(*Span)
+	if !ok {
+		return nil
+	}
+
+	span := t.startSpan(SpanNameAttempt, parent.SpanContext, time.Now())
+	span.Attributes["http.attempt"] = attemptNum + 1
+	t.inject(req.Header, span)
+	return span
+}
+
+func (t *tracer) endAttempt(span *Span, resp *Response, err error) {
+	if span == nil {
+		return
+	}
+
+	end := time.Now()
+	if resp == nil {
+		t.endSpan(span, end, err)
+		return
+	}
+	if ct := resp.clientTrace; ct != nil {
+		t.phase(span, SpanNameDNSLookup, ct.dnsStart, ct.dnsDone)
+		t.phase(span, SpanNameTCPConnect, ct.connStart, ct.connDone)
+		t.phase(span, SpanNameTLSHandshake, ct.tlsHandshakeStart, ct.tlsHandshakeDone)
+	}
+	if resp.Response != nil {
+		span.Attributes["http.status_code"] = resp.StatusCode
+	}
+	t.endSpan(span, end, err)
+}
+
+// Export a child span of parent for a phase captured by the client trace, if it happened.
+func (t *tracer) phase(parent *Span, name string, start time.Time, end time.Time) {
+	if start.IsZero() || end.IsZero() {
+		return
+	}
+	t.endSpan(t.startSpan(name, parent.SpanContext, start), end, nil)
+}
+
+func (t *tracer) inject(h http.Header, span *Span) {
+	sc := span.SpanContext
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	h.Set(headerTraceparent, fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags))
+	if sc.TraceState != "" {
+		h.Set(headerTracestate, sc.TraceState)
+	} else {
+		h.Del(headerTracestate)
+	}
+
+	if !t.b3 {
+		return
+	}
+	h.Set(headerB3TraceID, sc.TraceID.String())
+	h.Set(headerB3SpanID, sc.SpanID.String())
+	h.Set(headerB3ParentSpan, span.ParentSpanID.String())
+	if sc.Sampled {
+		h.Set(headerB3Sampled, "1")
+	} else {
+		h.Set(headerB3Sampled, "0")
+	}
+}
@@ -0,0 +1,164 @@
+package ghttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractSpanContext(t *testing.T) {
+	sc, ok := ExtractSpanContext(http.Header{
+		"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"Tracestate":  {"rojo=00f067aa0ba902b7", "congo=t61rcWkgMzE"},
+	})
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", sc.TraceState)
+
+	sc, ok = ExtractSpanContext(http.Header{
+		"X-B3-Traceid": {"a3ce929d0e0e4736"},
+		"X-B3-Spanid":  {"00f067aa0ba902b7"},
+		"X-B3-Sampled": {"0"},
+	})
+	require.True(t, ok)
+	assert.Equal(t, "0000000000000000a3ce929d0e0e4736", sc.TraceID.String())
+	assert.False(t, sc.Sampled)
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, traceparent := range invalid {
+		_, ok = ExtractSpanContext(http.Header{"Traceparent": {traceparent}})
+		assert.False(t, ok, traceparent)
+	}
+
+	// A future version may have more fields
+	_, ok = ExtractSpanContext(http.Header{"Traceparent": {"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"}})
+	assert.True(t, ok)
+}
+
+func TestClient_EnableTracing(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		headers []http.Header
+		counter uint64
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+		if atomic.AddUint64(&counter, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	exporter := NewInMemoryExporter()
+	client := New()
+	client.EnableTracing(exporter, WithTracingB3())
+
+	parent, ok := ExtractSpanContext(http.Header{
+		"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"Tracestate":  {"congo=t61rcWkgMzE"},
+	})
+	require.True(t, ok)
+	ctx := ContextWithSpanContext(context.Background(), parent)
+	_, err := client.Get(ts.URL+"/get?token=secret",
+		WithContext(ctx),
+		WithRetrier(
+			WithRetryBackoff(NewConstantBackoff(time.Millisecond, false)),
+			WithRetryTriggers(func(resp *Response, err error) bool {
+				return err != nil || resp.StatusCode != http.StatusOK
+			}),
+		),
+	)
+	require.NoError(t, err)
+
+	spans := exporter.Spans()
+	var attempts []*Span
+	var requestSpan *Span
+	for _, span := range spans {
+		assert.Equal(t, parent.TraceID, span.SpanContext.TraceID)
+		assert.False(t, span.EndTime.Before(span.StartTime))
+		switch span.Name {
+		case SpanNameAttempt:
+			attempts = append(attempts, span)
+		case "HTTP GET":
+			requestSpan = span
+		}
+	}
+	require.NotNil(t, requestSpan)
+	assert.Same(t, requestSpan, spans[len(spans)-1])
+	assert.Equal(t, parent.SpanID, requestSpan.ParentSpanID)
+	assert.Equal(t, ts.URL+"/get?token=REDACTED", requestSpan.Attributes["http.url"])
+	assert.Equal(t, http.StatusOK, requestSpan.Attributes["http.status_code"])
+
+	require.Len(t, attempts, 2)
+	require.Len(t, headers, 2)
+	for i, attempt := range attempts {
+		assert.Equal(t, requestSpan.SpanContext.SpanID, attempt.ParentSpanID)
+		assert.Equal(t, i+1, attempt.Attributes["http.attempt"])
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+attempt.SpanContext.SpanID.String()+"-01",
+			headers[i].Get("Traceparent"))
+		assert.Equal(t, "congo=t61rcWkgMzE", headers[i].Get("Tracestate"))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", headers[i].Get("X-B3-TraceId"))
+		assert.Equal(t, attempt.SpanContext.SpanID.String(), headers[i].Get("X-B3-SpanId"))
+		assert.Equal(t, requestSpan.SpanContext.SpanID.String(), headers[i].Get("X-B3-ParentSpanId"))
+		assert.Equal(t, "1", headers[i].Get("X-B3-Sampled"))
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, attempts[0].Attributes["http.status_code"])
+
+	// The first attempt dials a new connection
+	var connect *Span
+	for _, span := range spans {
+		if span.Name == SpanNameTCPConnect {
+			connect = span
+		}
+	}
+	require.NotNil(t, connect)
+	assert.Equal(t, attempts[0].SpanContext.SpanID, connect.ParentSpanID)
+
+	exporter.Reset()
+	assert.Empty(t, exporter.Spans())
+}
+
+func TestClient_EnableTracingNotSampled(t *testing.T) {
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+	}))
+	defer ts.Close()
+
+	exporter := NewInMemoryExporter()
+	client := New()
+	client.EnableTracing(exporter)
+
+	parent := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+	_, err := client.Get(ts.URL, WithContext(ContextWithSpanContext(context.Background(), parent)))
+	require.NoError(t, err)
+	assert.Empty(t, exporter.Spans())
+	assert.Regexp(t, "^00-"+parent.TraceID.String()+"-[0-9a-f]{16}-00$", traceparent)
+
+	// A new trace is started if there's no parent
+	_, err = client.Get(ts.URL)
+	require.NoError(t, err)
+	spans := exporter.Spans()
+	require.NotEmpty(t, spans)
+	assert.False(t, spans[len(spans)-1].ParentSpanID.IsValid())
+	assert.Regexp(t, "^00-"+spans[0].SpanContext.TraceID.String()+"-[0-9a-f]{16}-01$", traceparent)
+}
@@ -1,6 +1,9 @@
 package ghttp
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +11,7 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"sync"
 	"unsafe"
 
 	"github.com/winterssy/gjson"
@@ -17,6 +21,24 @@ type (
 	// H is an alias of gjson.Object.
 	// Visit https://github.com/winterssy/gjson for more details.
 	H = gjson.Object
+
+	// A body cancels its context when closed.
+	cancelBody struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+
+	multiReadCloser struct {
+		io.Reader
+		io.Closer
+	}
+
+	// A body calls done once when it's read to EOF or closed.
+	doneBody struct {
+		io.ReadCloser
+		once sync.Once
+		done func()
+	}
 )
 
 func b2s(b []byte) string {
@@ -122,6 +144,29 @@ func drainBody(body io.ReadCloser, w io.Writer) (err error) {
 	return
 }
 
+// Close implements io.Closer interface.
+func (cb *cancelBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.cancel()
+	return err
+}
+
+// Read implements io.Reader interface.
+func (db *doneBody) Read(p []byte) (int, error) {
+	n, err := db.ReadCloser.Read(p)
+	if err == io.EOF {
+		db.once.Do(db.done)
+	}
+	return n, err
+}
+
+// Close implements io.Closer interface.
+func (db *doneBody) Close() error {
+	err := db.ReadCloser.Close()
+	db.once.Do(db.done)
+	return err
+}
+
 func findCookie(name string, cookies []*http.Cookie) (*http.Cookie, error) {
 	for _, cookie := range cookies {
 		if cookie.Name == name {
@@ -132,6 +177,45 @@ func findCookie(name string, cookies []*http.Cookie) (*http.Cookie, error) {
 	return nil, http.ErrNoCookie
 }
 
+// Encode v as JSON into a new slice. The slice returned by gjson.Encode is backed by a pooled
+// buffer, which is already freed when it returns, so it must not be used at all.
+func encodeJSON(v interface{}, opts ...func(enc *gjson.Encoder)) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gjson.NewEncoder(&buf, opts...).Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// Return the first maxSize bytes of b and whether b is truncated,
+// while maxSize less than or equal to zero means no limit.
+func truncateBody(b []byte, maxSize int64) ([]byte, bool) {
+	if maxSize > 0 && int64(len(b)) > maxSize {
+		return b[:maxSize], true
+	}
+	return b, false
+}
+
+func cloneHeader(h http.Header) http.Header {
+	if h == nil {
+		return make(http.Header)
+	}
+	return h.Clone()
+}
+
+// Return a copy of h with the values of the headers that masked reports true replaced with RedactedValue.
+func maskHeader(h http.Header, masked func(key string) bool) http.Header {
+	redacted := cloneHeader(h)
+	for k, vs := range redacted {
+		if masked(k) {
+			for i := range vs {
+				vs[i] = RedactedValue
+			}
+		}
+	}
+	return redacted
+}
+
 // Return value if nonempty, def otherwise.
 func valueOrDefault(value, def string) string {
 	if value != "" {
@@ -139,3 +223,12 @@ func valueOrDefault(value, def string) string {
 	}
 	return def
 }
+
+// Return a random (version 4) UUID.
+func newUUID() string {
+	var uuid [16]byte
+	_, _ = rand.Read(uuid[:])
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
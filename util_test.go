@@ -3,6 +3,7 @@ package ghttp
 import (
 	"errors"
 	"io"
+	"net/http"
 	"testing"
 	"time"
 
@@ -125,3 +126,38 @@ func TestToStrings(t *testing.T) {
 	v = []interface{}{1, 2, 1 + 2i}
 	assert.Empty(t, toStrings(v))
 }
+
+func TestNewUUID(t *testing.T) {
+	uuid := newUUID()
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", uuid)
+	assert.NotEqual(t, uuid, newUUID())
+}
+
+func TestTruncateBody(t *testing.T) {
+	b, truncated := truncateBody([]byte("hello world"), 5)
+	assert.Equal(t, "hello", string(b))
+	assert.True(t, truncated)
+
+	b, truncated = truncateBody([]byte("hello"), 5)
+	assert.Equal(t, "hello", string(b))
+	assert.False(t, truncated)
+
+	b, truncated = truncateBody([]byte("hello"), 0)
+	assert.Equal(t, "hello", string(b))
+	assert.False(t, truncated)
+}
+
+func TestMaskHeader(t *testing.T) {
+	h := http.Header{
+		"Authorization": {"Bearer secret"},
+		"Accept":        {"application/json"},
+	}
+	redacted := maskHeader(h, func(key string) bool {
+		return key == "Authorization"
+	})
+	assert.Equal(t, RedactedValue, redacted.Get("Authorization"))
+	assert.Equal(t, "application/json", redacted.Get("Accept"))
+	assert.Equal(t, "Bearer secret", h.Get("Authorization"))
+
+	assert.NotNil(t, maskHeader(nil, func(string) bool { return true }))
+}
@@ -0,0 +1,392 @@
+package ghttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/winterssy/gjson"
+)
+
+const (
+	// RedactedValue is the value to replace the redacted header values with in a cassette.
+	RedactedValue = "REDACTED"
+
+	base64BodyEncoding = "base64"
+)
+
+// Modes of a recorder.
+const (
+	// RecorderModeRecord means requests are sent through the real transport,
+	// and the interactions are saved to the cassette, which is overwritten.
+	RecorderModeRecord RecorderMode = iota
+
+	// RecorderModeReplay means responses are served from the cassette,
+	// requests don't match any interaction fail with ErrInteractionNotFound.
+	RecorderModeReplay
+)
+
+var (
+	// ErrInteractionNotFound is returned by a replaying recorder when a request
+	// doesn't match any interaction of the cassette.
+	ErrInteractionNotFound = errors.New("ghttp: interaction not found in cassette")
+)
+
+type (
+	// RecorderMode is the mode of a recorder.
+	RecorderMode int
+
+	// Cassette is a collection of interactions recorded by a recorder.
+	Cassette struct {
+		Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+	}
+
+	// Interaction is a request and response pair.
+	Interaction struct {
+		Request  *RecordedRequest  `json:"request" yaml:"request"`
+		Response *RecordedResponse `json:"response" yaml:"response"`
+	}
+
+	// RecordedRequest is a request saved in a cassette.
+	RecordedRequest struct {
+		Method       string      `json:"method" yaml:"method"`
+		URL          string      `json:"url" yaml:"url"`
+		Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+		Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+		BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+	}
+
+	// RecordedResponse is a response saved in a cassette.
+	RecordedResponse struct {
+		StatusCode   int         `json:"status_code" yaml:"status_code"`
+		Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+		Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+		BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+	}
+
+	// CassetteMatcher reports whether a request matches a recorded one in a cassette.
+	CassetteMatcher func(req *RecordedRequest, recorded *RecordedRequest) bool
+
+	// Recorder is an http.RoundTripper that records the interactions to a cassette file,
+	// or replays them from it, which makes the tests hermetic.
+	// Install it with:
+	//   client.Transport = recorder
+	Recorder struct {
+		cassette      string
+		mode          RecorderMode
+		transport     http.RoundTripper
+		matchers      []CassetteMatcher
+		redactHeaders map[string]bool
+		filter        func(i *Interaction)
+		marshal       func(v interface{}) ([]byte, error)
+		unmarshal     func(data []byte, v interface{}) error
+
+		mu           sync.Mutex
+		interactions []*Interaction
+		replayed     map[*Interaction]bool
+	}
+
+	// RecorderOption configures a recorder.
+	RecorderOption func(r *Recorder)
+)
+
+// NewRecorder returns a recorder which works with the cassette file in mode.
+// In RecorderModeReplay, the cassette must exist.
+// By default, the cassette is encoded as JSON, requests are matched by method and URL,
+// and the Authorization and Proxy-Authorization headers are redacted.
+func NewRecorder(cassette string, mode RecorderMode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		cassette:      cassette,
+		mode:          mode,
+		transport:     DefaultTransport(),
+		matchers:      []CassetteMatcher{MatchMethod, MatchURL},
+		redactHeaders: map[string]bool{"Authorization": true, "Proxy-Authorization": true},
+		marshal: func(v interface{}) ([]byte, error) {
+			return encodeJSON(v, func(enc *gjson.Encoder) {
+				enc.SetEscapeHTML(false)
+				enc.SetIndent("", "  ")
+			})
+		},
+		unmarshal: func(data []byte, v interface{}) error {
+			return gjson.NewDecoder(bytes.NewReader(data)).Decode(v)
+		},
+		replayed: make(map[*Interaction]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if mode == RecorderModeReplay {
+		data, err := ioutil.ReadFile(cassette)
+		if err != nil {
+			return nil, err
+		}
+
+		c := new(Cassette)
+		if err = r.unmarshal(data, c); err != nil {
+			return nil, err
+		}
+		r.interactions = c.Interactions
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.mode == RecorderModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	rr := newRecordedRequest(req, body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Interactions are replayed in order, the last matched one is reused once all matched ones are replayed.
+	var matched *Interaction
+	for _, i := range r.interactions {
+		if !r.match(rr, i.Request) {
+			continue
+		}
+		matched = i
+		if !r.replayed[i] {
+			break
+		}
+	}
+	if matched == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+	}
+
+	r.replayed[matched] = true
+	respBody, err := decodeBody(matched.Response.Body, matched.Response.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", matched.Response.StatusCode, http.StatusText(matched.Response.StatusCode)),
+		StatusCode:    matched.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cloneHeader(matched.Response.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	outReq := req.Clone(req.Context())
+	if body != nil {
+		outReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := r.transport.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := &Interaction{
+		Request:  newRecordedRequest(req, body),
+		Response: newRecordedResponse(resp, respBody),
+	}
+	i.Request.Header = maskHeader(i.Request.Header, r.headerRedacted)
+	i.Response.Header = maskHeader(i.Response.Header, r.headerRedacted)
+	if r.filter != nil {
+		r.filter(i)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, i)
+	if err = r.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) headerRedacted(key string) bool {
+	return r.redactHeaders[key]
+}
+
+func (r *Recorder) match(req *RecordedRequest, recorded *RecordedRequest) bool {
+	for _, m := range r.matchers {
+		if !m(req, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// Write the cassette atomically, must be called with r.mu held.
+func (r *Recorder) save() error {
+	data, err := r.marshal(&Cassette{Interactions: r.interactions})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(r.cassette)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(dir, "cassette-")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), r.cassette)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+func newRecordedRequest(req *http.Request, body []byte) *RecordedRequest {
+	rr := &RecordedRequest{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: cloneHeader(req.Header),
+	}
+	rr.Body, rr.BodyEncoding = encodeBody(body)
+	return rr
+}
+
+func newRecordedResponse(resp *http.Response, body []byte) *RecordedResponse {
+	rr := &RecordedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     cloneHeader(resp.Header),
+	}
+	rr.Body, rr.BodyEncoding = encodeBody(body)
+	return rr
+}
+
+// Bodies which aren't valid UTF-8 are saved as base64.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), base64BodyEncoding
+}
+
+func decodeBody(body string, encoding string) ([]byte, error) {
+	if encoding == base64BodyEncoding {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+// MatchMethod is a matcher that matches requests by method.
+func MatchMethod(req *RecordedRequest, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL is a matcher that matches requests by URL.
+func MatchURL(req *RecordedRequest, recorded *RecordedRequest) bool {
+	return req.URL == recorded.URL
+}
+
+// MatchBody is a matcher that matches requests by body.
+func MatchBody(req *RecordedRequest, recorded *RecordedRequest) bool {
+	return req.Body == recorded.Body && req.BodyEncoding == recorded.BodyEncoding
+}
+
+// MatchHeaders returns a matcher that matches requests by the values of the specified headers.
+// Note that the redacted headers never match.
+func MatchHeaders(keys ...string) CassetteMatcher {
+	return func(req *RecordedRequest, recorded *RecordedRequest) bool {
+		for _, key := range keys {
+			key = http.CanonicalHeaderKey(key)
+			a, b := req.Header[key], recorded.Header[key]
+			if len(a) != len(b) {
+				return false
+			}
+			for i := range a {
+				if a[i] != b[i] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// WithRecorderTransport is a recorder option that specifies the real transport to send requests
+// in RecorderModeRecord.
+// By default is DefaultTransport().
+func WithRecorderTransport(transport http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// WithRecorderMatchers is a recorder option that specifies the matchers to find the interaction
+// to replay for a request, all of them must match.
+// By default are MatchMethod and MatchURL.
+func WithRecorderMatchers(matchers ...CassetteMatcher) RecorderOption {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithRecorderRedactHeaders is a recorder option that specifies the request and response headers
+// whose values are replaced with RedactedValue before saving.
+// By default are Authorization and Proxy-Authorization.
+func WithRecorderRedactHeaders(keys ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redactHeaders = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			r.redactHeaders[http.CanonicalHeaderKey(key)] = true
+		}
+	}
+}
+
+// WithRecorderFilter is a recorder option that specifies a callback to modify an interaction,
+// e.g. masking the secrets in body, before saving.
+func WithRecorderFilter(filter func(i *Interaction)) RecorderOption {
+	return func(r *Recorder) {
+		r.filter = filter
+	}
+}
+
+// WithRecorderCodec is a recorder option that specifies how to encode and decode the cassette,
+// e.g. passing yaml.Marshal and yaml.Unmarshal of gopkg.in/yaml.v2 for YAML cassettes.
+// By default is JSON.
+func WithRecorderCodec(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) RecorderOption {
+	return func(r *Recorder) {
+		r.marshal = marshal
+		r.unmarshal = unmarshal
+	}
+}
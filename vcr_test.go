@@ -0,0 +1,128 @@
+package ghttp
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		if r.URL.Path == "/binary" {
+			w.Write([]byte{0xff, 0xfe, 0x00})
+			return
+		}
+		w.Write(body)
+	}))
+
+	dir, err := ioutil.TempDir("", "ghttp-vcr")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cassette := filepath.Join(dir, "fixtures", "cassette.json")
+
+	recorder, err := NewRecorder(cassette, RecorderModeRecord,
+		WithRecorderFilter(func(i *Interaction) {
+			i.Request.Body = strings.Replace(i.Request.Body, "secret", "***", -1)
+			i.Response.Body = strings.Replace(i.Response.Body, "secret", "***", -1)
+		}),
+	)
+	require.NoError(t, err)
+
+	client := New()
+	client.Transport = recorder
+	resp, err := client.Post(ts.URL+"/echo",
+		WithBearerToken("token"),
+		WithText("hello secret"),
+	)
+	require.NoError(t, err)
+	result, err := resp.Text()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello secret", result)
+	}
+	_, err = client.Get(ts.URL + "/binary")
+	require.NoError(t, err)
+	ts.Close()
+
+	data, err := ioutil.ReadFile(cassette)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), RedactedValue)
+	assert.NotContains(t, string(data), "token")
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), "hello ***")
+	assert.Contains(t, string(data), `"body_encoding": "base64"`)
+
+	recorder, err = NewRecorder(cassette, RecorderModeReplay)
+	require.NoError(t, err)
+	client.Transport = recorder
+
+	for i := 0; i < 2; i++ {
+		resp, err = client.Post(ts.URL+"/echo", WithText("whatever"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, MethodPost, resp.Header.Get("X-Method"))
+		result, err = resp.Text()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "hello ***", result)
+		}
+	}
+
+	resp, err = client.Get(ts.URL + "/binary")
+	require.NoError(t, err)
+	b, err := resp.Content()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte{0xff, 0xfe, 0x00}, b)
+	}
+
+	_, err = client.Get(ts.URL + "/echo")
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+
+	recorder, err = NewRecorder(cassette, RecorderModeReplay,
+		WithRecorderMatchers(MatchMethod, MatchURL, MatchBody),
+	)
+	require.NoError(t, err)
+	client.Transport = recorder
+	_, err = client.Post(ts.URL+"/echo", WithText("whatever"))
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+	_, err = client.Post(ts.URL+"/echo", WithText("hello ***"))
+	assert.NoError(t, err)
+
+	_, err = NewRecorder(filepath.Join(dir, "missing.json"), RecorderModeReplay)
+	assert.Error(t, err)
+}
+
+func TestRecorder_Marshal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghttp-vcr")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder, err := NewRecorder(filepath.Join(dir, "cassette.json"), RecorderModeRecord)
+	require.NoError(t, err)
+	data, err := recorder.marshal(H{"msg": "hello"})
+	require.NoError(t, err)
+	want := string(data)
+
+	// The output must not be shared with the later encodings
+	_, err = recorder.marshal(H{"msg": "world"})
+	require.NoError(t, err)
+	assert.Equal(t, want, string(data))
+}
+
+func TestMatchHeaders(t *testing.T) {
+	req := &RecordedRequest{Header: http.Header{"Accept": {"application/json"}}}
+	recorded := &RecordedRequest{Header: http.Header{"Accept": {"application/json"}}}
+	assert.True(t, MatchHeaders("accept")(req, recorded))
+
+	recorded.Header.Set("Accept", "text/plain")
+	assert.False(t, MatchHeaders("Accept")(req, recorded))
+	assert.True(t, MatchHeaders("X-Foo")(req, recorded))
+}